@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/samber/headercheck"
+)
+
+func runExplain(name string, args []string) {
+	for _, exp := range explainFiles(name, args) {
+		rel := relTo(exp.Path)
+		fmt.Printf("%s:\n", rel)
+		if exp.CommentStyle != "" {
+			fmt.Printf("  comment style: %s\n", exp.CommentStyle)
+		} else {
+			fmt.Printf("  comment style: unknown\n")
+		}
+		for _, r := range exp.Rules {
+			status := "does not apply"
+			if r.Applies {
+				status = "applies"
+			}
+			fmt.Printf("  %s (%s): %s, %s", relTo(r.TemplatePath), r.Kind, status, r.Reason)
+			if r.Matches {
+				fmt.Printf(", matches the current header")
+			}
+			fmt.Println()
+		}
+		fmt.Printf("  result: %s\n", describeResult(exp.Result))
+	}
+}
+
+func runTemplates(name string, args []string) {
+	for _, exp := range explainFiles(name, args) {
+		for _, r := range exp.Rules {
+			if !r.Applies {
+				continue
+			}
+			fmt.Printf("==> %s: %s (%s) <==\n", relTo(exp.Path), relTo(r.TemplatePath), r.Kind)
+			if r.Rendered == nil {
+				fmt.Printf("(not rendered: no comment style known for this file)\n\n")
+				continue
+			}
+			fmt.Printf("%s\n", r.Rendered)
+		}
+	}
+}
+
+func explainFiles(name string, args []string) []headercheck.Explanation {
+	var o options
+	fs := newFlagSet(name, "[flags] files...")
+	registerConfigFlags(fs, &o)
+	_ = fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	rootAbs := mustGetwd()
+	ctx := context.Background()
+	c, _ := newChecker(ctx, rootAbs, &o, true)
+
+	var out []headercheck.Explanation
+	for _, p := range collectPaths(rootAbs, fs.Args()) {
+		exp, err := c.Explain(ctx, p)
+		if err != nil {
+			log.Fatalf("explain %s: %v", p, err)
+		}
+		out = append(out, exp)
+	}
+	return out
+}
+
+func describeResult(r headercheck.Result) string {
+	switch {
+	case r.Err != nil:
+		return fmt.Sprintf("error: %v", r.Err)
+	case r.Skipped != "":
+		return "skipped: " + r.Skipped
+	case r.Warning != "":
+		return "warning: " + r.Warning
+	case r.Action == headercheck.ActionNone && r.Template == "":
+		return "no template applies"
+	case r.Action == headercheck.ActionNone:
+		return fmt.Sprintf("ok, header matches %s", relTo(r.Template))
+	case r.Reason != "":
+		return fmt.Sprintf("%s: %s", r.Action, r.Reason)
+	}
+	return string(r.Action)
+}
+
+func relTo(path string) string {
+	rel, err := filepath.Rel(mustGetwd(), path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
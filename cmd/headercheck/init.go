@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const initConfig = `# headercheck configuration, see https://github.com/samber/headercheck
+templates:
+  - path: .header.txt
+    # include: (?i)\.(go|sh)$
+    # exclude: ^third_party/
+`
+
+func runInit(name string, args []string) {
+	var (
+		holder  string
+		license string
+		force   bool
+	)
+	fs := newFlagSet(name, "[flags]")
+	fs.StringVar(&holder, "holder", "", "copyright holder; asked for when stdin is a terminal")
+	fs.StringVar(&license, "license", "", "SPDX license identifier, eg MIT or Apache-2.0")
+	fs.BoolVar(&force, "force", false, "overwrite existing files")
+	_ = fs.Parse(args)
+
+	if holder == "" {
+		if !isTerminal(os.Stdin) {
+			log.Fatalf("--holder is required when stdin is not a terminal")
+		}
+		in := bufio.NewReader(os.Stdin)
+		holder = prompt(in, "Copyright holder: ")
+		if license == "" {
+			license = prompt(in, "SPDX license identifier (empty for none): ")
+		}
+	}
+	if holder == "" {
+		log.Fatalf("a copyright holder is required")
+	}
+
+	header := fmt.Sprintf("Copyright {{ year .Now }} %s\n", holder)
+	if license != "" {
+		header += fmt.Sprintf("SPDX-License-Identifier: %s\n", license)
+	}
+	rootAbs := mustGetwd()
+	files := []struct{ name, content string }{
+		{".header.txt", header},
+		{".headercheck.yaml", initConfig},
+	}
+	for _, f := range files {
+		if _, err := os.Stat(filepath.Join(rootAbs, f.name)); err == nil && !force {
+			log.Fatalf("%s already exists, use --force to overwrite it", f.name)
+		}
+	}
+	for _, f := range files {
+		if err := os.WriteFile(filepath.Join(rootAbs, f.name), []byte(f.content), 0o644); err != nil {
+			log.Fatalf("write %s: %v", f.name, err)
+		}
+		fmt.Printf("created %s\n", f.name)
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func prompt(in *bufio.Reader, question string) string {
+	fmt.Fprint(os.Stderr, question)
+	line, _ := in.ReadString('\n')
+	return strings.TrimSpace(line)
+}
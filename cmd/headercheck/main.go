@@ -8,13 +8,13 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"regexp"
+	"strconv"
 	"strings"
 
-	"github.com/samber/headercheck/internal/config"
-	"github.com/samber/headercheck/internal/engine"
-	"github.com/samber/headercheck/internal/gitmeta"
+	"github.com/samber/headercheck"
+	"github.com/samber/headercheck/internal/report"
 )
 
 type stringSlice []string
@@ -29,53 +29,202 @@ func (s *stringSlice) Set(v string) error {
 	return nil
 }
 
-func main() {
-	var (
-		configPaths stringSlice
-		fix         bool
-		force       bool
-		verbose     bool
-		templates   stringSlice
-		includeRe   string
-		excludeRe   string
-	)
-
-	parseFlags(&configPaths, &fix, &force, &verbose, &templates, &includeRe, &excludeRe)
+// autoBool is a boolean flag defaulting to a value picked from other flags.
+type autoBool struct {
+	value, set bool
+}
 
-	rootAbs := mustGetwd()
+func (b *autoBool) String() string {
+	if b == nil || !b.set {
+		return "auto"
+	}
+	return strconv.FormatBool(b.value)
+}
 
-	cfg := loadConfigs(rootAbs, configPaths)
-	cfg = applyTemplateFlags(rootAbs, cfg, templates, includeRe, excludeRe)
+func (b *autoBool) Set(v string) error {
+	value, err := strconv.ParseBool(v)
+	if err != nil {
+		return err
+	}
+	b.value, b.set = value, true
+	return nil
+}
 
-	ctx := context.Background()
-	gm := initGit(ctx, rootAbs, verbose)
+func (b *autoBool) IsBoolFlag() bool { return true }
 
-	rules := compileEngineRules(cfg)
+// or returns the value of the flag, or def when it is not set.
+func (b *autoBool) or(def bool) bool {
+	if !b.set {
+		return def
+	}
+	return b.value
+}
 
-	en := mustNewEngine(rootAbs, rules, force, verbose, gm)
+type options struct {
+	configPaths stringSlice
+	fix         bool
+	force       bool
+	verbose     bool
+	templates   stringSlice
+	removes     stringSlice
+	includeRe   string
+	excludeRe   string
+	gitIndex    autoBool
+	workers     int
+	maxIssues   int
+	format      string
+	showDiff    bool
+	rev         string
+	archive     string
+	since       string
+	staged      bool
+}
 
-	paths := collectPaths(rootAbs)
+var commands = map[string]func(name string, args []string){
+	"check":     runProcess,
+	"fix":       runProcess,
+	"diff":      runProcess,
+	"init":      runInit,
+	"explain":   runExplain,
+	"templates": runTemplates,
+}
 
-	results, err := runEngine(ctx, en, paths, fix)
-	if errors.Is(err, context.Canceled) {
-		os.Exit(2)
+func main() {
+	// without a command, arguments are the flags and paths of check
+	name, args := "check", os.Args[1:]
+	if len(args) > 0 {
+		if _, ok := commands[args[0]]; ok {
+			name, args = args[0], args[1:]
+		}
 	}
+	commands[name](name, args)
+}
+
+func runProcess(name string, args []string) {
+	var o options
+	fs := newFlagSet(name, "[flags] [paths...]")
+	registerConfigFlags(fs, &o)
+	fs.IntVar(&o.workers, "j", 0, "number of files processed concurrently (default GOMAXPROCS)")
+	fs.IntVar(&o.maxIssues, "max-issues", 0, "stop after this many files with issues (0 means no limit)")
+	fs.StringVar(&o.format, "format", "text", "output format: "+strings.Join(report.Formats(), ", "))
+	fs.StringVar(&o.rev, "rev", "", "check the files at this git revision, read from the object store instead of the working tree")
+	fs.StringVar(&o.archive, "archive", "", "check the files of this tar, tar.gz or zip archive instead of the working tree; .gitignore rules do not apply")
+	fs.StringVar(&o.since, "changed-since", "", "only process the files changed since this git ref (eg origin/main), including untracked files")
+	fs.BoolVar(&o.staged, "staged", false, "only process the files staged for the next commit")
+	if name == "check" {
+		// flags of the command-less CLI, kept for backwards compatibility
+		fs.BoolVar(&o.fix, "fix", false, "apply fixes: insert or update headers in place (same as the fix command)")
+		fs.BoolVar(&o.showDiff, "diff", false, "print the changes fix would make as unified diffs, without writing files (same as the diff command)")
+	}
+	_ = fs.Parse(args)
+	switch name {
+	case "fix":
+		o.fix = true
+	case "diff":
+		o.showDiff = true
+	}
+	if o.showDiff && o.fix {
+		log.Fatalf("--diff and --fix are mutually exclusive")
+	}
+	if o.showDiff && o.format != "text" {
+		log.Fatalf("--diff prints unified diffs, --format %s is not supported", o.format)
+	}
+	if o.rev != "" && o.archive != "" {
+		log.Fatalf("--rev and --archive are mutually exclusive")
+	}
+	if o.fix && (o.rev != "" || o.archive != "") {
+		log.Fatalf("--rev and --archive are read-only, use diff to see the fixes")
+	}
+	if o.since != "" && o.staged {
+		log.Fatalf("--changed-since and --staged are mutually exclusive")
+	}
+	if (o.since != "" || o.staged) && (o.rev != "" || o.archive != "") {
+		log.Fatalf("--changed-since and --staged apply to the working tree, not to --rev or --archive")
+	}
+
+	rootAbs := mustGetwd()
+
+	reportOpts := report.Options{Root: rootAbs, Fix: o.fix, Verbose: o.verbose, Out: os.Stdout, Err: os.Stderr}
+	reporter, err := report.New(o.format, reportOpts)
 	if err != nil {
-		log.Fatalf("processing error: %v", err)
+		log.Fatalf("%v", err)
 	}
 
-	handleResults(rootAbs, results, fix, verbose)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	c, fsys := newChecker(ctx, rootAbs, &o, o.showDiff)
+	if o.showDiff {
+		// compute fixes without writing them, and diff them against the
+		// files they were computed from
+		reportOpts.FS = fsys
+		reporter = report.NewDiff(reportOpts)
+	}
+
+	paths := collectPaths(rootAbs, fs.Args())
+
+	handleResults(ctx, c, paths, o.fix, o.showDiff, reporter)
 }
 
-func parseFlags(configPaths *stringSlice, fix, force, verbose *bool, templates *stringSlice, includeRe, excludeRe *string) {
-	flag.Var(configPaths, "config", "path(s) to .headercheck.yaml; can be repeated")
-	flag.BoolVar(fix, "fix", false, "apply fixes: insert or update headers in place")
-	flag.BoolVar(force, "force", false, "force processing of non-text/invalid files and print non-blocking warnings")
-	flag.BoolVar(verbose, "v", false, "verbose output")
-	flag.Var(templates, "template", "additional header template file path(s), comma-separated; can be repeated")
-	flag.StringVar(includeRe, "include", "", "regex of file paths to include (overrides config)")
-	flag.StringVar(excludeRe, "exclude", "", "regex of file paths to exclude (overrides config)")
-	flag.Parse()
+func newFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: headercheck %s %s\n\ncommands: check (default), fix, diff, init, explain, templates\n\nflags:\n", name, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func registerConfigFlags(fs *flag.FlagSet, o *options) {
+	fs.Var(&o.configPaths, "config", "path(s) to .headercheck.yaml; can be repeated")
+	fs.BoolVar(&o.force, "force", false, "force processing of non-text/invalid files and print non-blocking warnings")
+	fs.BoolVar(&o.verbose, "v", false, "verbose output")
+	fs.Var(&o.templates, "template", "additional header template file path(s), comma-separated; can be repeated")
+	fs.Var(&o.removes, "remove", "template file path(s) of headers to remove, comma-separated; can be repeated")
+	fs.StringVar(&o.includeRe, "include", "", "regex of file paths to include (overrides config)")
+	fs.StringVar(&o.excludeRe, "exclude", "", "regex of file paths to exclude (overrides config)")
+	fs.Var(&o.gitIndex, "git-index", "read git history once for all files instead of running git per file (default true, false with --changed-since and --staged)")
+}
+
+// newChecker returns the checker configured by o, and the file system it reads
+// files from, nil for the working tree.
+func newChecker(ctx context.Context, rootAbs string, o *options, dryRun bool) (*headercheck.Checker, headercheck.FS) {
+	cfg := loadConfigs(rootAbs, o.configPaths)
+	cfg = applyTemplateFlags(rootAbs, cfg, o.templates, o.includeRe, o.excludeRe, false)
+	cfg = applyTemplateFlags(rootAbs, cfg, o.removes, o.includeRe, o.excludeRe, true)
+
+	// reading the whole history costs more than running git for the few
+	// files --changed-since and --staged usually select
+	indexed := o.gitIndex.or(o.since == "" && !o.staged)
+
+	var gm headercheck.GitMetadata
+	var fsys headercheck.FS
+	switch {
+	case o.rev != "":
+		gm, fsys = openRevision(ctx, rootAbs, o.rev, indexed, cfg)
+	case o.archive != "":
+		// archives have no history: git variables are unknown
+		fsys = openArchive(rootAbs, o.archive, cfg)
+	default:
+		gm = initGit(ctx, rootAbs, indexed, o.verbose)
+	}
+	only := changedFiles(ctx, rootAbs, o)
+
+	c, err := headercheck.New(headercheck.Options{
+		Root:          rootAbs,
+		Config:        cfg,
+		Git:           gm,
+		Force:         o.force,
+		Workers:       o.workers,
+		MaxViolations: o.maxIssues,
+		DryRun:        dryRun,
+		FS:            fsys,
+		Only:          only,
+	})
+	if err != nil {
+		log.Fatalf("init error: %v", err)
+	}
+	return c, fsys
 }
 
 func mustGetwd() string {
@@ -86,19 +235,15 @@ func mustGetwd() string {
 	return rootAbs
 }
 
-func loadConfigs(rootAbs string, configPaths []string) config.Config {
-	var (
-		cfg config.Config
-		err error
-	)
+func loadConfigs(rootAbs string, configPaths []string) headercheck.Config {
 	if len(configPaths) == 0 {
-		cfg, err = config.Load("", rootAbs)
+		cfg, err := headercheck.LoadConfig("", rootAbs)
 		if err != nil {
 			log.Fatalf("config error: %v", err)
 		}
 		return cfg
 	}
-	cfg = config.Config{}
+	var configs []headercheck.Config
 	for _, p := range configPaths {
 		abs := p
 		if !filepath.IsAbs(abs) {
@@ -107,21 +252,21 @@ func loadConfigs(rootAbs string, configPaths []string) config.Config {
 		if _, statErr := os.Stat(abs); statErr != nil {
 			log.Fatalf("config file not found: %s", abs)
 		}
-		c, lerr := config.Load(abs, rootAbs)
+		c, lerr := headercheck.LoadConfig(abs, rootAbs)
 		if lerr != nil {
 			log.Fatalf("config error for %s: %v", abs, lerr)
 		}
-		cfg.Templates = append(cfg.Templates, c.Templates...)
+		configs = append(configs, c)
 	}
-	return cfg
+	return headercheck.MergeConfigs(configs...)
 }
 
-func applyTemplateFlags(rootAbs string, cfg config.Config, templates []string, includeRe, excludeRe string) config.Config {
+func applyTemplateFlags(rootAbs string, cfg headercheck.Config, templates []string, includeRe, excludeRe string, forbidden bool) headercheck.Config {
 	if len(templates) == 0 {
 		return cfg
 	}
 	for _, t := range templates {
-		td := config.TemplateDef{Path: t, Include: includeRe, Exclude: excludeRe}
+		td := headercheck.TemplateDef{Path: t, Include: includeRe, Exclude: excludeRe, Forbidden: forbidden}
 		if !filepath.IsAbs(td.Path) {
 			td.Path = filepath.Join(rootAbs, td.Path)
 		}
@@ -130,58 +275,39 @@ func applyTemplateFlags(rootAbs string, cfg config.Config, templates []string, i
 	return cfg
 }
 
-func initGit(ctx context.Context, rootAbs string, verbose bool) *gitmeta.Git {
-	gm, err := gitmeta.New(ctx, rootAbs)
+func initGit(ctx context.Context, rootAbs string, indexed, verbose bool) headercheck.GitMetadata {
+	gm, err := headercheck.OpenGit(ctx, rootAbs, indexed)
 	if err != nil {
 		if verbose {
-			log.Printf("warning: git metadata disabled: %v", err)
+			log.Printf("warning: git metadata disabled, .gitignore rules do not apply: %v", err)
 		}
-		return gitmeta.Disabled()
+		return nil
 	}
 	return gm
 }
 
-func compileEngineRules(cfg config.Config) []engine.TemplateRule {
-	var rules []engine.TemplateRule
-	for _, t := range cfg.Templates {
-		var incRx, excRx *regexp.Regexp
-		var err error
-		if t.Include == "" {
-			t.Include = engine.DefaultIncludeRegex
-		}
-		incRx, err = regexp.Compile(t.Include)
-		if err != nil {
-			log.Fatalf("invalid include regex for template %s: %v", t.Path, err)
-		}
-		if t.Exclude != "" {
-			excRx, err = regexp.Compile(t.Exclude)
-			if err != nil {
-				log.Fatalf("invalid exclude regex for template %s: %v", t.Path, err)
-			}
-		}
-		rules = append(rules, engine.TemplateRule{TemplatePath: t.Path, Include: incRx, Exclude: excRx})
+// changedFiles returns the files --changed-since or --staged restrict
+// processing to, nil to process every file.
+func changedFiles(ctx context.Context, rootAbs string, o *options) []string {
+	var files []string
+	var err error
+	switch {
+	case o.since != "":
+		files, err = headercheck.ChangedFiles(ctx, rootAbs, o.since)
+	case o.staged:
+		files, err = headercheck.StagedFiles(ctx, rootAbs)
+	default:
+		return nil
 	}
-	return rules
-}
-
-func mustNewEngine(rootAbs string, rules []engine.TemplateRule, force, verbose bool, gm *gitmeta.Git) *engine.Engine {
-	en, err := engine.New(engine.Options{
-		Root:       rootAbs,
-		Rules:      rules,
-		Force:      force,
-		Verbose:    verbose,
-		Git:        gm,
-		RespectGit: true,
-	})
 	if err != nil {
-		log.Fatalf("init error: %v", err)
+		log.Fatalf("git error: %v", err)
 	}
-	return en
+	return files
 }
 
-func collectPaths(rootAbs string) []string {
+func collectPaths(rootAbs string, args []string) []string {
 	// Collect paths from CLI, default to current directory.
-	paths := flag.Args()
+	paths := args
 	if len(paths) == 0 {
 		paths = []string{rootAbs}
 	}
@@ -193,34 +319,23 @@ func collectPaths(rootAbs string) []string {
 	return paths
 }
 
-func runEngine(ctx context.Context, en *engine.Engine, paths []string, fix bool) ([]engine.FileResult, error) {
-	results, err := en.Process(ctx, paths, fix)
-	return results, err
-}
-
-func handleResults(rootAbs string, results []engine.FileResult, fix, verbose bool) {
+func handleResults(ctx context.Context, c *headercheck.Checker, paths []string, fix, dryRun bool, reporter report.Reporter) {
 	var hadIssues bool
-	for _, r := range results {
-		if r.Warning != "" {
-			fmt.Fprintf(os.Stderr, "warning: %s: %s\n", r.Path, r.Warning)
-		}
-		if r.Err != nil {
-			// non-fatal per file
-			fmt.Fprintf(os.Stderr, "error: %s: %v\n", r.Path, r.Err)
-			hadIssues = true
-			continue
-		}
-		if r.Action == engine.ActionNone {
-			continue
-		}
-		if !fix && r.Action != engine.ActionNone {
-			// report as linter issue style
-			rel, _ := filepath.Rel(rootAbs, r.Path)
-			fmt.Printf("%s:1: missing or incorrect header (%s)\n", rel, r.Action)
+	// dry runs process files as fixes, and report them as checks
+	err := c.Run(ctx, paths, fix || dryRun, func(r headercheck.Result) error {
+		if isIssue(r, fix) {
 			hadIssues = true
-		} else if fix && verbose {
-			fmt.Printf("fixed: %s (%s)\n", r.Path, r.Action)
 		}
+		return reporter.Report(r)
+	})
+	if errors.Is(err, context.Canceled) {
+		os.Exit(2)
+	}
+	if err != nil && !errors.Is(err, headercheck.ErrMaxViolations) {
+		log.Fatalf("processing error: %v", err)
+	}
+	if err := reporter.Close(); err != nil {
+		log.Fatalf("write report: %v", err)
 	}
 
 	if hadIssues && !fix {
@@ -228,3 +343,7 @@ func handleResults(rootAbs string, results []engine.FileResult, fix, verbose boo
 		os.Exit(1)
 	}
 }
+
+func isIssue(r headercheck.Result, fix bool) bool {
+	return r.Err != nil || (!fix && r.Action != headercheck.ActionNone)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/samber/headercheck"
+)
+
+// openRevision returns the git metadata and the files of the revision rev.
+func openRevision(ctx context.Context, rootAbs, rev string, indexed bool, cfg headercheck.Config) (headercheck.GitMetadata, headercheck.FS) {
+	gm, err := headercheck.OpenGitAt(ctx, rootAbs, rev, indexed)
+	if err != nil {
+		log.Fatalf("git error: %v", err)
+	}
+	// only the files templates apply to are read
+	keep, err := headercheck.FileFilter(cfg)
+	if err != nil {
+		log.Fatalf("init error: %v", err)
+	}
+	fsys, err := headercheck.ReadRevision(ctx, rootAbs, rev, keep)
+	if err != nil {
+		log.Fatalf("git error: %v", err)
+	}
+	return gm, newSnapshotFS(fsys, rootAbs, cfg)
+}
+
+// openArchive returns the files of the archive at path.
+func openArchive(rootAbs, path string, cfg headercheck.Config) headercheck.FS {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(rootAbs, path)
+	}
+	fsys, err := headercheck.ReadArchive(path)
+	if err != nil {
+		log.Fatalf("archive error: %v", err)
+	}
+	return newSnapshotFS(fsys, rootAbs, cfg)
+}
+
+// snapshotFS serves the files of a revision or an archive under the root, and
+// the templates from the working tree: snapshots are checked against the
+// current configuration.
+type snapshotFS struct {
+	headercheck.FS
+	templates map[string]bool
+}
+
+func newSnapshotFS(fsys fs.FS, rootAbs string, cfg headercheck.Config) snapshotFS {
+	templates := map[string]bool{}
+	for _, t := range cfg.Templates {
+		templates[t.Path] = true
+		for _, r := range t.Replaces {
+			templates[r] = true
+		}
+	}
+	return snapshotFS{FS: headercheck.FromFS(fsys, rootAbs), templates: templates}
+}
+
+func (s snapshotFS) ReadFile(name string) ([]byte, error) {
+	if s.templates[name] {
+		return os.ReadFile(name)
+	}
+	return s.FS.ReadFile(name)
+}
@@ -0,0 +1,386 @@
+// Package headercheck checks and fixes the license headers of source files.
+//
+// Load a configuration, build a Checker and run it over files or directories:
+//
+//	cfg, err := headercheck.LoadConfig("", root)
+//	if err != nil {
+//		return err
+//	}
+//	c, err := headercheck.New(headercheck.Options{Root: root, Config: cfg})
+//	if err != nil {
+//		return err
+//	}
+//	results, err := c.Check(ctx, root)
+//
+// This package is the stable API of headercheck and follows semantic
+// versioning: the identifiers it exports, including the fields of the types it
+// aliases, are not removed or changed incompatibly within a major version. The
+// packages under internal/ may change at any time.
+package headercheck
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+
+	"github.com/samber/headercheck/internal/config"
+	"github.com/samber/headercheck/internal/engine"
+	"github.com/samber/headercheck/internal/gitmeta"
+	"github.com/samber/headercheck/internal/snapshot"
+)
+
+type (
+	// Config is the content of a .headercheck.yaml file.
+	Config = config.Config
+	// TemplateDef configures a template: its path, the files it applies to
+	// and how it applies.
+	TemplateDef = config.TemplateDef
+	// CommentStyleDef configures a comment style.
+	CommentStyleDef = config.CommentStyleDef
+
+	// Result is the verdict on a file, with the fixed content in fix mode.
+	Result = engine.FileResult
+	// Action is what a file needs, or got in fix mode.
+	Action = engine.Action
+	// Edit is the change fixing a file, see Result.Fix.
+	Edit = engine.Edit
+
+	// Explanation tells how a file is handled, see Checker.Explain.
+	Explanation = engine.Explanation
+	// RuleExplanation tells how a template applies to a file.
+	RuleExplanation = engine.RuleExplanation
+
+	// GitMetadata provides the git variables of files, such as their author.
+	GitMetadata = engine.GitMetadata
+
+	// FS is the file system files and templates are read from, and fixes are
+	// written to. Names are OS paths.
+	FS = engine.FS
+	// OSFS is the file system of the operating system, the default FS.
+	OSFS = engine.OSFS
+)
+
+// Actions of results.
+const (
+	ActionNone    = engine.ActionNone
+	ActionInsert  = engine.ActionInsert
+	ActionReplace = engine.ActionReplace
+	ActionRemove  = engine.ActionRemove
+	ActionMove    = engine.ActionMove
+)
+
+// Kinds of templates, see RuleExplanation.
+const (
+	RuleRequired   = engine.RuleRequired
+	RuleForbidden  = engine.RuleForbidden
+	RuleDeprecated = engine.RuleDeprecated
+)
+
+// DefaultIncludeRegex matches the source files of common languages. It is the
+// include pattern of templates without one.
+const DefaultIncludeRegex = engine.DefaultIncludeRegex
+
+// ErrMaxViolations is returned when a run stopped after
+// Options.MaxViolations files with issues.
+var ErrMaxViolations = engine.ErrMaxViolations
+
+// ErrReadOnly is returned when fixing files of a read-only FS, see FromFS.
+var ErrReadOnly = engine.ErrReadOnly
+
+// FromFS returns a read-only FS serving the files of fsys, such as an archive,
+// an embedded tree or a fstest.MapFS, as if they were located under the OS
+// directory root.
+func FromFS(fsys fs.FS, root string) FS {
+	return engine.FromFS(fsys, root)
+}
+
+// LoadConfig loads the configuration file at path, relative to root, or the
+// first of .headercheck.yaml, .headercheck.yml, headercheck.yaml and
+// headercheck.yml found in root when path is empty. Without configuration
+// file, the template is root/.header.txt.
+func LoadConfig(path, root string) (Config, error) {
+	return config.Load(path, root)
+}
+
+// MergeConfigs merges configurations: templates and skipped directories are
+// appended, comment styles and variables of later configurations win, and
+// switches are on when any configuration turns them on.
+func MergeConfigs(configs ...Config) Config {
+	var cfg Config
+	for _, c := range configs {
+		cfg.Templates = append(cfg.Templates, c.Templates...)
+		for k, v := range c.CommentStyles {
+			if cfg.CommentStyles == nil {
+				cfg.CommentStyles = map[string]CommentStyleDef{}
+			}
+			cfg.CommentStyles[k] = v
+		}
+		for k, v := range c.Variables {
+			if cfg.Variables == nil {
+				cfg.Variables = map[string]string{}
+			}
+			cfg.Variables[k] = v
+		}
+		cfg.IncludeGenerated = cfg.IncludeGenerated || c.IncludeGenerated
+		cfg.FollowSymlinks = cfg.FollowSymlinks || c.FollowSymlinks
+		cfg.EnforcePlacement = cfg.EnforcePlacement || c.EnforcePlacement
+		if c.SkipDirs != nil {
+			cfg.SkipDirs = append(append([]string{}, cfg.SkipDirs...), c.SkipDirs...)
+		}
+	}
+	return cfg
+}
+
+// OpenGit returns the git metadata of the repository containing root. When
+// indexed, the history is read once for all files, which is much faster on
+// large trees than running git for each file.
+func OpenGit(ctx context.Context, root string, indexed bool) (GitMetadata, error) {
+	if indexed {
+		return gitmeta.NewIndexed(ctx, root)
+	}
+	return gitmeta.New(ctx, root)
+}
+
+// OpenGitAt is like OpenGit, with metadata as of the revision rev, such as a
+// tag or a commit: later history is ignored and no file has pending changes.
+// Use it along with ReadRevision.
+func OpenGitAt(ctx context.Context, root, rev string, indexed bool) (GitMetadata, error) {
+	if indexed {
+		return gitmeta.NewIndexedAt(ctx, root, rev)
+	}
+	return gitmeta.NewAt(ctx, root, rev)
+}
+
+// ReadRevision reads the files under root at the git revision rev from the
+// object store, without checking it out. Serve them with FromFS. Only the
+// files keep accepts, by slash-separated path relative to root, are listed:
+// see FileFilter. A nil keep lists every file.
+//
+// Files are read when opened, by a git process running until ctx is done or
+// the returned fs.FS, which implements io.Closer, is closed.
+func ReadRevision(ctx context.Context, root, rev string, keep func(name string) bool) (fs.FS, error) {
+	f, err := snapshot.ReadRevision(ctx, root, rev, keep)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// FileFilter returns a function reporting whether a template of cfg applies to
+// a file, by slash-separated path relative to the root, as the keep argument
+// of ReadRevision.
+func FileFilter(cfg Config) (func(name string) bool, error) {
+	rules, err := compileRules(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return func(name string) bool {
+		return engine.AnyRuleApplies(rules, filepath.FromSlash(name))
+	}, nil
+}
+
+// ReadArchive reads the files of a tar, gzip-compressed tar or zip archive.
+// When every file is under a single directory, as in release tarballs, that
+// directory is the root of the returned fs.FS. Serve them with FromFS.
+func ReadArchive(path string) (fs.FS, error) {
+	f, err := snapshot.ReadArchive(path)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// ChangedFiles lists the files under root changed since ref, such as the base
+// branch of a pull request: files committed since the merge base of ref and
+// HEAD, staged, modified or untracked. Renamed files are listed under their new
+// name, deleted and gitignored files are left out. Pass them as Options.Only.
+func ChangedFiles(ctx context.Context, root, ref string) ([]string, error) {
+	g, err := gitmeta.New(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	return g.ChangedSince(ctx, ref)
+}
+
+// StagedFiles lists the files under root staged for the next commit. Renamed
+// files are listed under their new name, deleted files are left out. Pass them
+// as Options.Only.
+func StagedFiles(ctx context.Context, root string) ([]string, error) {
+	g, err := gitmeta.New(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	return g.Staged(ctx)
+}
+
+// Options configures a Checker.
+type Options struct {
+	// Root is the directory paths are reported relative to, and matched
+	// against include and exclude patterns from.
+	Root   string
+	Config Config
+	// Git provides the git variables of files, see OpenGit. Without it, git
+	// variables are unknown and gitignored files are walked.
+	Git GitMetadata
+	// Force checks non-UTF-8 files with a warning instead of skipping them.
+	Force bool
+	// Workers is the number of files processed concurrently. Defaults to
+	// GOMAXPROCS.
+	Workers int
+	// MaxViolations stops runs once that many files have issues. 0 means no
+	// limit.
+	MaxViolations int
+	// DryRun computes fixes without writing files, see Result.NewContent.
+	DryRun bool
+	// FS is the file system files and templates are read from. Defaults to
+	// OSFS.
+	FS FS
+	// Only restricts runs to these files, eg from ChangedFiles. Relative
+	// paths, here and among the paths checked, are resolved against Root.
+	// Directories holding none of them are not walked. nil checks every file.
+	Only []string
+}
+
+// Checker checks and fixes headers. It is safe for concurrent use.
+type Checker struct {
+	en *engine.Engine
+}
+
+// New returns a Checker applying the templates of opts.Config.
+func New(opts Options) (*Checker, error) {
+	rules, err := compileRules(opts.Config)
+	if err != nil {
+		return nil, err
+	}
+	styles, err := compileCommentStyles(opts.Config)
+	if err != nil {
+		return nil, err
+	}
+	git := opts.Git
+	if git == nil {
+		git = gitmeta.Disabled()
+	}
+	en, err := engine.New(engine.Options{
+		Root:             opts.Root,
+		Rules:            rules,
+		Force:            opts.Force,
+		Git:              git,
+		RespectGit:       true,
+		CommentStyles:    styles,
+		Variables:        opts.Config.Variables,
+		Workers:          opts.Workers,
+		MaxViolations:    opts.MaxViolations,
+		DryRun:           opts.DryRun,
+		IncludeGenerated: opts.Config.IncludeGenerated,
+		EnforcePlacement: opts.Config.EnforcePlacement,
+		SkipDirs:         opts.Config.SkipDirs,
+		FollowSymlinks:   opts.Config.FollowSymlinks,
+		FS:               opts.FS,
+		Only:             opts.Only,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Checker{en: en}, nil
+}
+
+// Check checks the files and directories, walked recursively, and returns
+// the results in walk order.
+func (c *Checker) Check(ctx context.Context, paths ...string) ([]Result, error) {
+	return c.en.Process(ctx, paths, false)
+}
+
+// Fix fixes the files and directories, walked recursively, and returns the
+// results in walk order.
+func (c *Checker) Fix(ctx context.Context, paths ...string) ([]Result, error) {
+	return c.en.Process(ctx, paths, true)
+}
+
+// Run checks or fixes the files and directories and calls fn with the result
+// of each file, in walk order, as soon as it is known. Run stops at the first
+// error returned by fn and returns it.
+func (c *Checker) Run(ctx context.Context, paths []string, fix bool, fn func(Result) error) error {
+	return c.en.ProcessFunc(ctx, paths, fix, fn)
+}
+
+// CheckContent checks content as the content of the file at path, without
+// touching the file, eg for editor buffers or generated code. path is
+// absolute or relative to Options.Root: it selects the templates, and git
+// variables are resolved from it.
+func (c *Checker) CheckContent(ctx context.Context, path string, content []byte) Result {
+	return c.en.CheckContent(ctx, path, content)
+}
+
+// FixContent is like CheckContent, and returns the fixed content in
+// Result.NewContent, nil when content needs no change. Nothing is written.
+func (c *Checker) FixContent(ctx context.Context, path string, content []byte) Result {
+	return c.en.FixContent(ctx, path, content)
+}
+
+// Explain tells which templates apply to the file, how they render and which
+// ones match its header, along with the result of checking it.
+func (c *Checker) Explain(ctx context.Context, path string) (Explanation, error) {
+	return c.en.Explain(ctx, path)
+}
+
+func compileRules(cfg Config) ([]engine.TemplateRule, error) {
+	var rules []engine.TemplateRule
+	for _, t := range cfg.Templates {
+		var incRx, excRx *regexp.Regexp
+		var err error
+		if t.Include == "" {
+			t.Include = engine.DefaultIncludeRegex
+		}
+		incRx, err = regexp.Compile(t.Include)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include regex for template %s: %w", t.Path, err)
+		}
+		if t.Exclude != "" {
+			excRx, err = regexp.Compile(t.Exclude)
+			if err != nil {
+				return nil, fmt.Errorf("invalid exclude regex for template %s: %w", t.Path, err)
+			}
+		}
+		rules = append(rules, engine.TemplateRule{TemplatePath: t.Path, Include: incRx, Exclude: excRx, CommentStyle: t.CommentStyle, Forbidden: t.Forbidden, Replaces: t.Replaces})
+	}
+	return rules, nil
+}
+
+func compileCommentStyles(cfg Config) (map[string]engine.CommentStyle, error) {
+	if len(cfg.CommentStyles) == 0 {
+		return nil, nil
+	}
+	styles := make(map[string]engine.CommentStyle, len(cfg.CommentStyles))
+	for key, def := range cfg.CommentStyles {
+		cs := engine.CommentStyle{Name: key}
+		if def.Style != "" {
+			base, ok := engine.CommentStyleByName(def.Style)
+			if !ok {
+				return nil, fmt.Errorf("unknown comment style %q for %s", def.Style, key)
+			}
+			cs = base
+		}
+		if def.Line != "" {
+			cs.Line = def.Line
+		}
+		if def.BlockStart != "" {
+			cs.BlockStart = def.BlockStart
+		}
+		if def.BlockLine != "" {
+			cs.BlockLine = def.BlockLine
+		}
+		if def.BlockEnd != "" {
+			cs.BlockEnd = def.BlockEnd
+		}
+		if def.PreferBlock {
+			cs.PreferBlock = true
+		}
+		if cs.Line == "" && (cs.BlockStart == "" || cs.BlockEnd == "") {
+			return nil, fmt.Errorf("comment style %s needs either a line prefix or block delimiters", key)
+		}
+		styles[key] = cs
+	}
+	return styles, nil
+}
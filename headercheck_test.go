@@ -0,0 +1,89 @@
+package headercheck
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChecker_CheckAndFix(t *testing.T) {
+	dir := t.TempDir()
+	mustWrite(t, filepath.Join(dir, ".header.txt"), "Copyright Acme\n")
+	mustWrite(t, filepath.Join(dir, ".headercheck.yaml"), "templates:\n  - path: .header.txt\nvariables:\n  team: core\n")
+	src := filepath.Join(dir, "main.go")
+	mustWrite(t, src, "package main\n")
+
+	cfg, err := LoadConfig("", dir)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	c, err := New(Options{Root: dir, Config: cfg})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	res, err := c.Check(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	var issues []Result
+	for _, r := range res {
+		if r.Action != ActionNone {
+			issues = append(issues, r)
+		}
+	}
+	if len(issues) != 1 || issues[0].Path != src || issues[0].Action != ActionInsert {
+		t.Fatalf("unexpected results: %+v", res)
+	}
+
+	if _, err := c.Fix(context.Background(), src); err != nil {
+		t.Fatalf("fix: %v", err)
+	}
+	b, _ := os.ReadFile(src)
+	if got, want := string(b), "// Copyright Acme\n\npackage main\n"; got != want {
+		t.Fatalf("GOT:\n%s\nWANT:\n%s", got, want)
+	}
+}
+
+func TestChecker_FixContent(t *testing.T) {
+	dir := t.TempDir()
+	mustWrite(t, filepath.Join(dir, ".header.txt"), "Copyright Acme\n")
+	cfg, _ := LoadConfig("", dir)
+	c, err := New(Options{Root: dir, Config: cfg})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	r := c.FixContent(context.Background(), "main.go", []byte("package main\n"))
+	if got, want := string(r.NewContent), "// Copyright Acme\n\npackage main\n"; r.Action != ActionInsert || got != want {
+		t.Fatalf("unexpected result: %+v\nGOT:\n%s", r, got)
+	}
+	if r := c.CheckContent(context.Background(), "main.go", r.NewContent); r.Action != ActionNone {
+		t.Fatalf("fixed content must be ok: %+v", r)
+	}
+}
+
+func TestNew_RejectsInvalidConfig(t *testing.T) {
+	if _, err := New(Options{Config: Config{Templates: []TemplateDef{{Path: "x", Include: "("}}}}); err == nil {
+		t.Fatalf("expected an error for an invalid include regex")
+	}
+	if _, err := New(Options{Config: Config{CommentStyles: map[string]CommentStyleDef{".foo": {Style: "nope"}}}}); err == nil {
+		t.Fatalf("expected an error for an unknown comment style")
+	}
+}
+
+func TestMergeConfigs(t *testing.T) {
+	cfg := MergeConfigs(
+		Config{Templates: []TemplateDef{{Path: "a"}}, Variables: map[string]string{"k": "1"}},
+		Config{Templates: []TemplateDef{{Path: "b"}}, Variables: map[string]string{"k": "2"}, IncludeGenerated: true, SkipDirs: []string{}},
+	)
+	if len(cfg.Templates) != 2 || cfg.Variables["k"] != "2" || !cfg.IncludeGenerated || cfg.SkipDirs == nil {
+		t.Fatalf("unexpected merge: %+v", cfg)
+	}
+}
+
+func mustWrite(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
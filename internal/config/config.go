@@ -17,6 +17,26 @@ type TemplateDef struct {
 	Path    string `yaml:"path"`
 	Include string `yaml:"include"`
 	Exclude string `yaml:"exclude"`
+	// CommentStyle forces the comment style used to render a neutral template
+	// (a built-in style name or a key of Config.CommentStyles).
+	CommentStyle string `yaml:"comment_style"`
+	// Forbidden marks a template whose headers must be removed from files.
+	Forbidden bool `yaml:"forbidden"`
+	// Replaces lists deprecated templates: headers matching them are swapped
+	// for this template, eg when relicensing.
+	Replaces []string `yaml:"replaces"`
+}
+
+// CommentStyleDef describes a comment style in configuration. `comment_styles`
+// entries can be either a built-in style name or an object whose fields
+// override the style named by `style` (if any).
+type CommentStyleDef struct {
+	Style       string `yaml:"style"`
+	Line        string `yaml:"line"`
+	BlockStart  string `yaml:"block_start"`
+	BlockLine   string `yaml:"block_line"`
+	BlockEnd    string `yaml:"block_end"`
+	PreferBlock bool   `yaml:"prefer_block"`
 }
 
 // Config represents headercheck configuration.
@@ -26,6 +46,23 @@ type Config struct {
 	// Legacy/global defaults (optional): applied to templates without include/exclude
 	Include string `yaml:"include"`
 	Exclude string `yaml:"exclude"`
+	// CommentStyles extends the built-in extension->comment style registry.
+	// Keys are file extensions (".foo"), base names ("jenkinsfile") or custom style names.
+	CommentStyles map[string]CommentStyleDef `yaml:"comment_styles"`
+	// Variables are exposed to Go text/template headers as {{ .Vars.name }}.
+	Variables map[string]string `yaml:"variables"`
+	// IncludeGenerated checks generated files, skipped by default.
+	IncludeGenerated bool `yaml:"include_generated"`
+	// SkipDirs replaces the default list of directories not walked. Nil when
+	// not configured. Files git ignores are not walked either, inside a git
+	// repository only: .gitignore files are not read otherwise.
+	SkipDirs []string `yaml:"skip_dirs"`
+	// FollowSymlinks checks and fixes the targets of symbolic links, skipped
+	// by default.
+	FollowSymlinks bool `yaml:"follow_symlinks"`
+	// EnforcePlacement reports correct headers found below directives, and
+	// fixes move them above. They are accepted by default.
+	EnforcePlacement bool `yaml:"enforce_placement"`
 }
 
 // Load loads configuration from explicit path or common defaults.
@@ -89,6 +126,13 @@ func Load(explicitPath string, root string) (Config, error) {
 						if exc, ok := v["exclude"].(string); ok {
 							def.Exclude = exc
 						}
+						if cs, ok := v["comment_style"].(string); ok {
+							def.CommentStyle = cs
+						}
+						if f, ok := v["forbidden"].(bool); ok {
+							def.Forbidden = f
+						}
+						def.Replaces = parseStringList(v["replaces"])
 						if strings.TrimSpace(def.Path) != "" {
 							defs = append(defs, def)
 						}
@@ -105,6 +149,28 @@ func Load(explicitPath string, root string) (Config, error) {
 		if exc, ok := raw["exclude"].(string); ok && strings.TrimSpace(exc) != "" {
 			cfg.Exclude = exc
 		}
+		if cv, ok := raw["comment_styles"].(map[string]interface{}); ok {
+			cfg.CommentStyles = parseCommentStyles(cv)
+		}
+		if vv, ok := raw["variables"].(map[string]interface{}); ok {
+			cfg.Variables = make(map[string]string, len(vv))
+			for k, v := range vv {
+				cfg.Variables[k] = fmt.Sprint(v)
+			}
+		}
+		if ig, ok := raw["include_generated"].(bool); ok {
+			cfg.IncludeGenerated = ig
+		}
+		if fs, ok := raw["follow_symlinks"].(bool); ok {
+			cfg.FollowSymlinks = fs
+		}
+		if ep, ok := raw["enforce_placement"].(bool); ok {
+			cfg.EnforcePlacement = ep
+		}
+		if sd, ok := raw["skip_dirs"]; ok {
+			// an empty list disables the defaults
+			cfg.SkipDirs = append([]string{}, parseStringList(sd)...)
+		}
 	}
 
 	// Normalize template paths and apply defaults
@@ -113,6 +179,11 @@ func Load(explicitPath string, root string) (Config, error) {
 		if !filepath.IsAbs(t.Path) {
 			t.Path = filepath.Join(root, t.Path)
 		}
+		for j, r := range t.Replaces {
+			if !filepath.IsAbs(r) {
+				t.Replaces[j] = filepath.Join(root, r)
+			}
+		}
 		if t.Include == "" {
 			t.Include = cfg.Include
 		}
@@ -133,3 +204,58 @@ func Load(explicitPath string, root string) (Config, error) {
 
 	return cfg, nil
 }
+
+// parseStringList parses a value that is either a string or a list of strings.
+func parseStringList(v interface{}) []string {
+	var out []string
+	switch vv := v.(type) {
+	case string:
+		if strings.TrimSpace(vv) != "" {
+			out = append(out, vv)
+		}
+	case []interface{}:
+		for _, it := range vv {
+			if s, ok := it.(string); ok && strings.TrimSpace(s) != "" {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
+
+// parseCommentStyles parses the flexible `comment_styles` mapping.
+func parseCommentStyles(raw map[string]interface{}) map[string]CommentStyleDef {
+	out := make(map[string]CommentStyleDef, len(raw))
+	for key, it := range raw {
+		key = strings.ToLower(strings.TrimSpace(key))
+		if key == "" {
+			continue
+		}
+		switch v := it.(type) {
+		case string:
+			out[key] = CommentStyleDef{Style: v}
+		case map[string]interface{}:
+			def := CommentStyleDef{}
+			if s, ok := v["style"].(string); ok {
+				def.Style = s
+			}
+			if s, ok := v["line"].(string); ok {
+				def.Line = s
+			}
+			if s, ok := v["block_start"].(string); ok {
+				def.BlockStart = s
+			}
+			if s, ok := v["block_line"].(string); ok {
+				def.BlockLine = s
+			}
+			if s, ok := v["block_end"].(string); ok {
+				def.BlockEnd = s
+			}
+			if b, ok := v["prefer_block"].(bool); ok {
+				def.PreferBlock = b
+			}
+			out[key] = def
+		}
+	}
+	return out
+}
@@ -67,6 +67,117 @@ exclude: "^vendor/"
 	}
 }
 
+func TestLoad_ParsesCommentStyles(t *testing.T) {
+	dir := t.TempDir()
+	conf := []byte(`
+templates:
+  - path: ".header.txt"
+    comment_style: c-block
+  - path: ".old.txt"
+    forbidden: true
+  - path: ".apache.txt"
+    replaces: [".mit.txt", "/abs/bsd.txt"]
+comment_styles:
+  .foo: hash
+  .BAR:
+    line: "!"
+`)
+	mustWrite(t, filepath.Join(dir, ".headercheck.yaml"), conf)
+	cfg, err := Load("", dir)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Templates[0].CommentStyle != "c-block" {
+		t.Fatalf("template comment_style not parsed: %+v", cfg.Templates[0])
+	}
+	if cfg.Templates[0].Forbidden || !cfg.Templates[1].Forbidden {
+		t.Fatalf("template forbidden not parsed: %+v", cfg.Templates)
+	}
+	if r := cfg.Templates[2].Replaces; len(r) != 2 || r[0] != filepath.Join(dir, ".mit.txt") || r[1] != "/abs/bsd.txt" {
+		t.Fatalf("template replaces not parsed: %+v", cfg.Templates[2])
+	}
+	if cfg.CommentStyles[".foo"].Style != "hash" {
+		t.Fatalf("string comment style not parsed: %+v", cfg.CommentStyles)
+	}
+	if cfg.CommentStyles[".bar"].Line != "!" {
+		t.Fatalf("object comment style not parsed: %+v", cfg.CommentStyles)
+	}
+}
+
+func TestLoad_ParsesVariables(t *testing.T) {
+	dir := t.TempDir()
+	conf := []byte(`
+variables:
+  company: Acme
+  since: 2019
+`)
+	mustWrite(t, filepath.Join(dir, ".headercheck.yaml"), conf)
+	cfg, err := Load("", dir)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Variables["company"] != "Acme" || cfg.Variables["since"] != "2019" {
+		t.Fatalf("variables not parsed: %+v", cfg.Variables)
+	}
+}
+
+func TestLoad_ParsesIncludeGenerated(t *testing.T) {
+	dir := t.TempDir()
+	mustWrite(t, filepath.Join(dir, ".headercheck.yaml"), []byte("include_generated: true\n"))
+	cfg, err := Load("", dir)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !cfg.IncludeGenerated {
+		t.Fatalf("include_generated not parsed: %+v", cfg)
+	}
+}
+
+func TestLoad_ParsesFollowSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	mustWrite(t, filepath.Join(dir, ".headercheck.yaml"), []byte("follow_symlinks: true\n"))
+	cfg, err := Load("", dir)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !cfg.FollowSymlinks {
+		t.Fatalf("follow_symlinks not parsed: %+v", cfg)
+	}
+}
+
+func TestLoad_ParsesEnforcePlacement(t *testing.T) {
+	dir := t.TempDir()
+	mustWrite(t, filepath.Join(dir, ".headercheck.yaml"), []byte("enforce_placement: true\n"))
+	cfg, err := Load("", dir)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !cfg.EnforcePlacement {
+		t.Fatalf("enforce_placement not parsed: %+v", cfg)
+	}
+}
+
+func TestLoad_ParsesSkipDirs(t *testing.T) {
+	dir := t.TempDir()
+	mustWrite(t, filepath.Join(dir, ".headercheck.yaml"), []byte("skip_dirs: [dist, third_party/*]\n"))
+	cfg, err := Load("", dir)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(cfg.SkipDirs) != 2 || cfg.SkipDirs[0] != "dist" || cfg.SkipDirs[1] != "third_party/*" {
+		t.Fatalf("skip_dirs not parsed: %+v", cfg.SkipDirs)
+	}
+
+	mustWrite(t, filepath.Join(dir, ".headercheck.yaml"), []byte("skip_dirs: []\n"))
+	if cfg, _ = Load("", dir); cfg.SkipDirs == nil || len(cfg.SkipDirs) != 0 {
+		t.Fatalf("empty skip_dirs must disable the defaults: %#v", cfg.SkipDirs)
+	}
+	mustWrite(t, filepath.Join(dir, ".headercheck.yaml"), []byte("include_generated: true\n"))
+	if cfg, _ = Load("", dir); cfg.SkipDirs != nil {
+		t.Fatalf("skip_dirs must be nil when not configured: %#v", cfg.SkipDirs)
+	}
+}
+
 func mustWrite(t *testing.T, path string, b []byte) {
 	t.Helper()
 	if err := os.WriteFile(path, b, 0o666); err != nil {
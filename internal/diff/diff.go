@@ -0,0 +1,197 @@
+// Package diff computes line-based unified diffs.
+package diff
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// context is the number of unchanged lines shown around changes.
+const context = 3
+
+// maxTableSize bounds the size of the table used to diff the changed part of
+// two files. Above it, the changed part is shown as removed then added.
+const maxTableSize = 1 << 22
+
+// op is a line of the edit script turning a into b.
+type op struct {
+	kind byte // ' ', '-' or '+'
+	line []byte
+	// aPos and bPos are the number of lines of a and b before this line.
+	aPos, bPos int
+}
+
+// Unified returns the unified diff turning a into b, labelled with oldName and
+// newName, or nil when a and b are equal.
+func Unified(oldName, newName string, a, b []byte) []byte {
+	if bytes.Equal(a, b) {
+		return nil
+	}
+	ops := editScript(splitLines(a), splitLines(b))
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+	hunkStart, lastChange := -1, -1
+	for i, o := range ops {
+		if o.kind == ' ' {
+			continue
+		}
+		if hunkStart >= 0 && i-lastChange-1 > 2*context {
+			writeHunk(&out, ops[hunkStart:lastChange+1+context])
+			hunkStart = -1
+		}
+		if hunkStart < 0 {
+			hunkStart = max(i-context, 0)
+		}
+		lastChange = i
+	}
+	writeHunk(&out, ops[hunkStart:min(lastChange+1+context, len(ops))])
+	return out.Bytes()
+}
+
+// splitLines splits b after each newline. The last line may have no newline.
+func splitLines(b []byte) [][]byte {
+	var lines [][]byte
+	for len(b) > 0 {
+		end := bytes.IndexByte(b, '\n') + 1
+		if end == 0 {
+			end = len(b)
+		}
+		lines = append(lines, b[:end])
+		b = b[end:]
+	}
+	return lines
+}
+
+// editScript returns the shortest edit script turning a into b. Common lines
+// at both ends are trimmed before diffing the rest with a LCS table, which is
+// cheap for headers that only change the top of files.
+func editScript(a, b [][]byte) []op {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && bytes.Equal(a[prefix], b[prefix]) {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && bytes.Equal(a[len(a)-1-suffix], b[len(b)-1-suffix]) {
+		suffix++
+	}
+
+	var ops []op
+	i, j := 0, 0
+	emit := func(kind byte) {
+		o := op{kind: kind, aPos: i, bPos: j}
+		switch kind {
+		case ' ':
+			o.line = a[i]
+			i++
+			j++
+		case '-':
+			o.line = a[i]
+			i++
+		case '+':
+			o.line = b[j]
+			j++
+		}
+		ops = append(ops, o)
+	}
+
+	for i < prefix {
+		emit(' ')
+	}
+	ma, mb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if (len(ma)+1)*(len(mb)+1) > maxTableSize {
+		for range ma {
+			emit('-')
+		}
+		for range mb {
+			emit('+')
+		}
+	} else {
+		// lcs[x][y] is the length of the longest common subsequence of ma[x:] and mb[y:]
+		lcs := make([][]int32, len(ma)+1)
+		for x := range lcs {
+			lcs[x] = make([]int32, len(mb)+1)
+		}
+		for x := len(ma) - 1; x >= 0; x-- {
+			for y := len(mb) - 1; y >= 0; y-- {
+				if bytes.Equal(ma[x], mb[y]) {
+					lcs[x][y] = lcs[x+1][y+1] + 1
+				} else {
+					lcs[x][y] = max32(lcs[x+1][y], lcs[x][y+1])
+				}
+			}
+		}
+		x, y := 0, 0
+		for x < len(ma) || y < len(mb) {
+			switch {
+			case x < len(ma) && y < len(mb) && bytes.Equal(ma[x], mb[y]):
+				emit(' ')
+				x++
+				y++
+			case y == len(mb) || (x < len(ma) && lcs[x+1][y] >= lcs[x][y+1]):
+				emit('-')
+				x++
+			default:
+				emit('+')
+				y++
+			}
+		}
+	}
+	for i < len(a) {
+		emit(' ')
+	}
+	return ops
+}
+
+// writeHunk writes a hunk made of the given lines.
+func writeHunk(out *bytes.Buffer, ops []op) {
+	var aLen, bLen int
+	for _, o := range ops {
+		if o.kind != '+' {
+			aLen++
+		}
+		if o.kind != '-' {
+			bLen++
+		}
+	}
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(ops[0].aPos, aLen), hunkRange(ops[0].bPos, bLen))
+	for _, o := range ops {
+		out.WriteByte(o.kind)
+		out.Write(o.line)
+		if !bytes.HasSuffix(o.line, []byte("\n")) {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// hunkRange formats the range of a hunk starting after pos lines.
+func hunkRange(pos, n int) string {
+	switch n {
+	case 0:
+		return fmt.Sprintf("%d,0", pos)
+	case 1:
+		return fmt.Sprintf("%d", pos+1)
+	}
+	return fmt.Sprintf("%d,%d", pos+1, n)
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max32(a, b int32) int32 {
+	if a > b {
+		return a
+	}
+	return b
+}
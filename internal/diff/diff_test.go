@@ -0,0 +1,62 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "equal",
+			a:    "a\nb\n",
+			b:    "a\nb\n",
+			want: "",
+		},
+		{
+			name: "insert at top",
+			a:    "package main\n\nfunc main() {}\n",
+			b:    "// Copyright Acme\n\npackage main\n\nfunc main() {}\n",
+			want: "--- a/x.go\n+++ b/x.go\n@@ -1,3 +1,5 @@\n+// Copyright Acme\n+\n package main\n \n func main() {}\n",
+		},
+		{
+			name: "replace",
+			a:    "// Copyright Foo\n\npackage main\n",
+			b:    "// Copyright Acme\n\npackage main\n",
+			want: "--- a/x.go\n+++ b/x.go\n@@ -1,3 +1,3 @@\n-// Copyright Foo\n+// Copyright Acme\n \n package main\n",
+		},
+		{
+			name: "missing newline",
+			a:    "x",
+			b:    "y",
+			want: "--- a/x.go\n+++ b/x.go\n@@ -1 +1 @@\n-x\n\\ No newline at end of file\n+y\n\\ No newline at end of file\n",
+		},
+	}
+	for _, c := range cases {
+		got := string(Unified("a/x.go", "b/x.go", []byte(c.a), []byte(c.b)))
+		if got != c.want {
+			t.Fatalf("%s:\nGOT:\n%s\nWANT:\n%s", c.name, got, c.want)
+		}
+	}
+}
+
+func TestUnified_SeparateHunks(t *testing.T) {
+	var a, b []string
+	for i := 0; i < 20; i++ {
+		a = append(a, "line")
+		b = append(b, "line")
+	}
+	a[0], b[0] = "old top", "new top"
+	a[19], b[19] = "old bottom", "new bottom"
+	got := string(Unified("a", "b", []byte(strings.Join(a, "\n")+"\n"), []byte(strings.Join(b, "\n")+"\n")))
+	if n := strings.Count(got, "@@ -"); n != 2 {
+		t.Fatalf("expected 2 hunks, got %d:\n%s", n, got)
+	}
+	if !strings.Contains(got, "@@ -1,4 +1,4 @@\n-old top\n+new top\n") || !strings.Contains(got, "@@ -17,4 +17,4 @@\n line\n line\n line\n-old bottom\n+new bottom\n") {
+		t.Fatalf("unexpected hunks:\n%s", got)
+	}
+}
@@ -0,0 +1,208 @@
+package engine
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+)
+
+// CommentStyle describes how a neutral header template is wrapped in comments
+// for a given file type.
+type CommentStyle struct {
+	// Name identifies the style in configuration (eg "slash", "hash").
+	Name string
+	// Line is the single-line comment prefix (eg "//", "#"). Empty when the
+	// language only has block comments.
+	Line string
+	// BlockStart, BlockLine and BlockEnd describe block comments, eg "/*", " *", " */".
+	// BlockLine may be empty when inner lines carry no prefix (eg HTML).
+	BlockStart string
+	BlockLine  string
+	BlockEnd   string
+	// PreferBlock renders headers as a block comment even when Line is set.
+	PreferBlock bool
+}
+
+// builtinCommentStyles lists the comment styles known by name.
+var builtinCommentStyles = map[string]CommentStyle{
+	"slash":     {Name: "slash", Line: "//", BlockStart: "/*", BlockLine: " *", BlockEnd: " */"},
+	"c-block":   {Name: "c-block", BlockStart: "/*", BlockLine: " *", BlockEnd: " */"},
+	"hash":      {Name: "hash", Line: "#"},
+	"python":    {Name: "python", Line: "#", BlockStart: `"""`, BlockEnd: `"""`},
+	"dash":      {Name: "dash", Line: "--"},
+	"lua":       {Name: "lua", Line: "--", BlockStart: "--[[", BlockEnd: "]]"},
+	"haskell":   {Name: "haskell", Line: "--", BlockStart: "{-", BlockEnd: "-}"},
+	"semicolon": {Name: "semicolon", Line: ";"},
+	"percent":   {Name: "percent", Line: "%"},
+	"quote":     {Name: "quote", Line: "'"},
+	"rem":       {Name: "rem", Line: "REM"},
+	"html":      {Name: "html", BlockStart: "<!--", BlockEnd: "-->"},
+	"ml":        {Name: "ml", BlockStart: "(*", BlockEnd: "*)"},
+}
+
+// builtinCommentStyleByExt maps lower-cased file extensions (with leading dot)
+// and well-known base names to a built-in comment style name.
+var builtinCommentStyleByExt = map[string]string{
+	// C family
+	".go": "slash", ".c": "slash", ".h": "slash", ".hpp": "slash", ".hh": "slash", ".cc": "slash",
+	".cpp": "slash", ".cxx": "slash", ".cs": "slash", ".java": "slash", ".kt": "slash", ".kts": "slash",
+	".ts": "slash", ".tsx": "slash", ".js": "slash", ".jsx": "slash", ".mjs": "slash", ".cjs": "slash",
+	".rs": "slash", ".php": "slash", ".swift": "slash", ".m": "slash", ".mm": "slash", ".scala": "slash",
+	".proto": "slash", ".dart": "slash", ".groovy": "slash", ".gradle": "slash", ".sbt": "slash",
+	".zig": "slash", ".fs": "slash", ".fsi": "slash", ".fsx": "slash", ".scss": "slash", ".less": "slash",
+	".css": "c-block",
+	// hash
+	".sh": "hash", ".bash": "hash", ".zsh": "hash", ".fish": "hash", ".py": "python", ".rb": "hash",
+	".pl": "hash", ".pm": "hash", ".r": "hash", ".jl": "hash", ".yaml": "hash", ".yml": "hash",
+	".toml": "hash", ".make": "hash", ".mk": "hash", ".cmake": "hash", ".dockerfile": "hash",
+	".ps1": "hash", ".psm1": "hash", ".ex": "hash", ".exs": "hash", ".nim": "hash", ".coffee": "hash",
+	".tf": "hash", ".cfg": "hash", ".conf": "hash",
+	"makefile": "hash", "dockerfile": "hash", "gemfile": "hash", "rakefile": "hash",
+	// dash
+	".sql": "dash", ".lua": "lua", ".hs": "haskell", ".elm": "haskell", ".adb": "dash", ".ads": "dash",
+	// others
+	".clj": "semicolon", ".cljs": "semicolon", ".edn": "semicolon", ".el": "semicolon", ".lisp": "semicolon",
+	".scm": "semicolon", ".asm": "semicolon", ".ini": "semicolon",
+	".erl": "percent", ".hrl": "percent", ".tex": "percent",
+	".vb": "quote", ".vbs": "quote", ".bas": "quote",
+	".bat": "rem", ".cmd": "rem",
+	".html": "html", ".htm": "html", ".xml": "html", ".xhtml": "html", ".svg": "html", ".md": "html",
+	".vue": "html", ".xsl": "html", ".xsd": "html",
+	".ml": "ml", ".mli": "ml", ".sml": "ml", ".pas": "ml",
+}
+
+// CommentStyleByName returns the built-in comment style with the given name.
+func CommentStyleByName(name string) (CommentStyle, bool) {
+	cs, ok := builtinCommentStyles[strings.ToLower(strings.TrimSpace(name))]
+	return cs, ok
+}
+
+// commentStyleKey returns the registry key for a path: its lower-cased extension,
+// or its lower-cased base name when it has no extension.
+func commentStyleKey(path string) string {
+	base := strings.ToLower(filepath.Base(path))
+	if ext := filepath.Ext(base); ext != "" && ext != base {
+		return ext
+	}
+	return base
+}
+
+// commentStyleForPath looks up the comment style of a file, first in the
+// configured overrides, then in the built-in registry.
+func (e *Engine) commentStyleForPath(path string) *CommentStyle {
+	key := commentStyleKey(path)
+	if cs, ok := e.opts.CommentStyles[key]; ok {
+		return &cs
+	}
+	if name, ok := builtinCommentStyleByExt[key]; ok {
+		cs := builtinCommentStyles[name]
+		return &cs
+	}
+	return nil
+}
+
+// commentStyleForRule returns the style used to render the rule for path. The
+// template's own comment_style wins over the registry.
+func (e *Engine) commentStyleForRule(tr TemplateRule, path string) *CommentStyle {
+	if cs, ok := e.namedCommentStyle(tr.CommentStyle); ok {
+		return &cs
+	}
+	return e.commentStyleForPath(path)
+}
+
+// namedCommentStyle resolves a style name against the configured styles first,
+// then the built-in ones.
+func (e *Engine) namedCommentStyle(name string) (CommentStyle, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return CommentStyle{}, false
+	}
+	if cs, ok := e.opts.CommentStyles[name]; ok {
+		return cs, true
+	}
+	return CommentStyleByName(name)
+}
+
+// renderComment wraps a neutral text in comments of the given style.
+// Blank lines inside the text are kept as bare comment markers.
+func renderComment(text []byte, cs *CommentStyle) []byte {
+	lines := strings.Split(strings.TrimRight(string(text), "\n\r\t "), "\n")
+	var out bytes.Buffer
+	if cs.Line != "" && !cs.PreferBlock {
+		for _, l := range lines {
+			l = strings.TrimRight(l, " \t")
+			if l == "" {
+				out.WriteString(cs.Line + "\n")
+				continue
+			}
+			out.WriteString(cs.Line + " " + l + "\n")
+		}
+		return out.Bytes()
+	}
+	out.WriteString(cs.BlockStart + "\n")
+	for _, l := range lines {
+		l = strings.TrimRight(l, " \t")
+		switch {
+		case cs.BlockLine == "":
+			out.WriteString(l + "\n")
+		case l == "":
+			out.WriteString(cs.BlockLine + "\n")
+		default:
+			out.WriteString(cs.BlockLine + " " + l + "\n")
+		}
+	}
+	out.WriteString(cs.BlockEnd + "\n")
+	return out.Bytes()
+}
+
+// commentOpeners are the markers used to recognize templates that already
+// carry their own comment syntax. Markers that may begin plain text ("%" for
+// variables, "'") are deliberately left out.
+var commentOpeners = []string{"//", "/*", "#", ";", "--", "<!--", "(*", "{-", "REM ", `"""`}
+
+// isPreCommented reports whether a template is written with comment markers
+// baked in, as opposed to a neutral text the engine has to wrap.
+func isPreCommented(content []byte) bool {
+	for _, l := range strings.Split(string(content), "\n") {
+		s := strings.TrimSpace(l)
+		if s == "" {
+			continue
+		}
+		for _, m := range commentOpeners {
+			if strings.HasPrefix(s, m) {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// stripCommentMarkers removes comment markers from every line of a header so
+// that line and block renderings of the same text compare equal.
+func stripCommentMarkers(header []byte, cs *CommentStyle) []byte {
+	suffixes := []string{"*/"}
+	prefixes := []string{"/*", "//", "*", "#", ";"}
+	if cs != nil {
+		suffixes = append([]string{cs.BlockEnd}, suffixes...)
+		prefixes = append([]string{cs.BlockStart, cs.Line, cs.BlockLine}, prefixes...)
+	}
+	var out bytes.Buffer
+	for _, l := range strings.Split(string(header), "\n") {
+		s := strings.TrimSpace(l)
+		for _, sfx := range suffixes {
+			if sfx = strings.TrimSpace(sfx); sfx != "" && strings.HasSuffix(s, sfx) {
+				s = strings.TrimSpace(strings.TrimSuffix(s, sfx))
+				break
+			}
+		}
+		for _, p := range prefixes {
+			if p = strings.TrimSpace(p); p != "" && strings.HasPrefix(s, p) {
+				s = strings.TrimSpace(strings.TrimPrefix(s, p))
+				break
+			}
+		}
+		out.WriteString(s + "\n")
+	}
+	return out.Bytes()
+}
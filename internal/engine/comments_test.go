@@ -0,0 +1,142 @@
+package engine
+
+import (
+	"context"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRenderComment_LineAndBlockStyles(t *testing.T) {
+	text := []byte("Copyright Acme\n\nLicensed MIT\n")
+	cases := []struct {
+		style string
+		want  string
+	}{
+		{"slash", "// Copyright Acme\n//\n// Licensed MIT\n"},
+		{"hash", "# Copyright Acme\n#\n# Licensed MIT\n"},
+		{"rem", "REM Copyright Acme\nREM\nREM Licensed MIT\n"},
+		{"c-block", "/*\n * Copyright Acme\n *\n * Licensed MIT\n */\n"},
+		{"html", "<!--\nCopyright Acme\n\nLicensed MIT\n-->\n"},
+		{"ml", "(*\nCopyright Acme\n\nLicensed MIT\n*)\n"},
+		{"python", "# Copyright Acme\n#\n# Licensed MIT\n"},
+	}
+	for _, c := range cases {
+		cs, ok := CommentStyleByName(c.style)
+		if !ok {
+			t.Fatalf("unknown style %s", c.style)
+		}
+		if got := string(renderComment(text, &cs)); got != c.want {
+			t.Fatalf("%s: got %q want %q", c.style, got, c.want)
+		}
+	}
+}
+
+func TestIsPreCommented(t *testing.T) {
+	if !isPreCommented([]byte("\n// Copyright\n")) {
+		t.Fatalf("expected slash template to be pre-commented")
+	}
+	if !isPreCommented([]byte("# Copyright\n")) {
+		t.Fatalf("expected hash template to be pre-commented")
+	}
+	if isPreCommented([]byte("Copyright %author%\n// not a comment start\n")) {
+		t.Fatalf("expected neutral template")
+	}
+}
+
+func TestNeutralTemplate_RenderedPerFileType(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "tmpl.txt")
+	mustWrite(t, tmpl, []byte("Copyright Acme\nAuthor: %author%\n"))
+	goSrc := filepath.Join(dir, "main.go")
+	mustWrite(t, goSrc, []byte("package main\n"))
+	shSrc := filepath.Join(dir, "run.sh")
+	mustWrite(t, shSrc, []byte("#!/bin/sh\necho hi\n"))
+	sqlSrc := filepath.Join(dir, "q.sql")
+	mustWrite(t, sqlSrc, []byte("SELECT 1;\n"))
+
+	rules := []TemplateRule{{TemplatePath: tmpl, Include: regexp.MustCompile(DefaultIncludeRegex)}}
+	e, err := New(Options{Root: dir, Rules: rules, Git: &fakeGit{author: "Alice", touched: true}, RespectGit: true})
+	if err != nil {
+		t.Fatalf("init engine: %v", err)
+	}
+	if _, err := e.Process(context.Background(), []string{goSrc, shSrc, sqlSrc}, true); err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	if got := string(mustRead(t, goSrc)); !strings.HasPrefix(got, "// Copyright Acme\n// Author: Alice\n\npackage main") {
+		t.Fatalf("go header:\n%s", got)
+	}
+	if got := string(mustRead(t, shSrc)); !strings.HasPrefix(got, "#!/bin/sh\n\n# Copyright Acme\n# Author: Alice\n\necho hi") {
+		t.Fatalf("sh header:\n%s", got)
+	}
+	if got := string(mustRead(t, sqlSrc)); !strings.HasPrefix(got, "-- Copyright Acme\n-- Author: Alice\n\nSELECT 1;") {
+		t.Fatalf("sql header:\n%s", got)
+	}
+
+	// A second pass must be a no-op in check mode.
+	res, err := e.Process(context.Background(), []string{goSrc, shSrc, sqlSrc}, false)
+	if err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	for _, r := range res {
+		if r.Action != ActionNone {
+			t.Fatalf("expected no action after fix, got: %+v", r)
+		}
+	}
+}
+
+func TestNeutralTemplate_CustomCommentStyles(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "tmpl.txt")
+	mustWrite(t, tmpl, []byte("Copyright Acme\n"))
+	src := filepath.Join(dir, "conf.foo")
+	mustWrite(t, src, []byte("key = value\n"))
+	unknown := filepath.Join(dir, "data.bar")
+	mustWrite(t, unknown, []byte("raw\n"))
+
+	rules := []TemplateRule{{TemplatePath: tmpl, Include: regexp.MustCompile(`\.(foo|bar)$`)}}
+	styles := map[string]CommentStyle{".foo": {Name: "bang", Line: "!"}}
+	e, err := New(Options{Root: dir, Rules: rules, Git: &fakeGit{touched: true}, CommentStyles: styles})
+	if err != nil {
+		t.Fatalf("init engine: %v", err)
+	}
+	res, err := e.Process(context.Background(), []string{src, unknown}, true)
+	if err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	if got := string(mustRead(t, src)); !strings.HasPrefix(got, "! Copyright Acme\n\nkey = value") {
+		t.Fatalf("custom style header:\n%s", got)
+	}
+	if res[1].Action != ActionNone || res[1].Warning == "" {
+		t.Fatalf("expected warning for unknown comment style, got: %+v", res[1])
+	}
+	if string(mustRead(t, unknown)) != "raw\n" {
+		t.Fatalf("file without comment style must be left untouched")
+	}
+}
+
+func TestNeutralTemplate_ForcedBlockStyle(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "tmpl.txt")
+	mustWrite(t, tmpl, []byte("Copyright Acme\n"))
+	src := filepath.Join(dir, "Main.java")
+	mustWrite(t, src, []byte("class Main {}\n"))
+
+	rules := []TemplateRule{{TemplatePath: tmpl, Include: regexp.MustCompile(DefaultIncludeRegex), CommentStyle: "c-block"}}
+	e, err := New(Options{Root: dir, Rules: rules, Git: &fakeGit{touched: true}})
+	if err != nil {
+		t.Fatalf("init engine: %v", err)
+	}
+	if _, err := e.Process(context.Background(), []string{src}, true); err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	if got := string(mustRead(t, src)); !strings.HasPrefix(got, "/*\n * Copyright Acme\n */\n\nclass Main {}") {
+		t.Fatalf("block header:\n%s", got)
+	}
+
+	_, err = New(Options{Root: dir, Rules: []TemplateRule{{TemplatePath: tmpl, CommentStyle: "nope"}}, Git: &fakeGit{}})
+	if err == nil {
+		t.Fatalf("expected error for unknown comment style")
+	}
+}
@@ -0,0 +1,30 @@
+package engine
+
+import (
+	"context"
+	"path/filepath"
+)
+
+// CheckContent checks content as the content of the file at path, without
+// reading the file. path is absolute or relative to Options.Root: it selects
+// the templates and comment style, and git metadata is resolved from it.
+func (e *Engine) CheckContent(ctx context.Context, path string, content []byte) FileResult {
+	return e.processInMemory(ctx, path, content, false)
+}
+
+// FixContent is like CheckContent, and returns the fixed content in
+// FileResult.NewContent, nil when content needs no change. Nothing is
+// written, whatever Options.DryRun.
+func (e *Engine) FixContent(ctx context.Context, path string, content []byte) FileResult {
+	return e.processInMemory(ctx, path, content, true)
+}
+
+func (e *Engine) processInMemory(ctx context.Context, path string, content []byte, fix bool) FileResult {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(e.opts.Root, path)
+	}
+	if !e.appliesTo(path) {
+		return FileResult{Path: path, Action: ActionNone}
+	}
+	return e.processContent(ctx, path, content, fix)
+}
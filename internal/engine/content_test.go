@@ -0,0 +1,42 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestCheckAndFixContent_DoNotTouchDisk(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "tmpl.txt")
+	mustWrite(t, tmpl, []byte("Copyright Acme\n"))
+
+	rules := []TemplateRule{{TemplatePath: tmpl, Include: regexp.MustCompile(DefaultIncludeRegex)}}
+	e, _ := New(Options{Root: dir, Rules: rules, Git: &fakeGit{touched: true}})
+	content := []byte("package main\r\n")
+
+	res := e.CheckContent(context.Background(), "app/main.go", content)
+	if res.Err != nil || res.Action != ActionInsert || res.Path != filepath.Join(dir, "app/main.go") || res.NewContent != nil {
+		t.Fatalf("unexpected check result: %+v", res)
+	}
+	res = e.FixContent(context.Background(), "app/main.go", content)
+	if got, want := string(res.NewContent), "// Copyright Acme\r\n\r\npackage main\r\n"; res.Err != nil || got != want {
+		t.Fatalf("unexpected fix result: %+v\nGOT:\n%q\nWANT:\n%q", res, got, want)
+	}
+	if string(content) != "package main\r\n" {
+		t.Fatalf("content must not be modified: %q", content)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "app")); !os.IsNotExist(err) {
+		t.Fatalf("nothing must be written, stat: %v", err)
+	}
+
+	res = e.FixContent(context.Background(), "main.go", res.NewContent)
+	if res.Action != ActionNone || res.NewContent != nil {
+		t.Fatalf("fixed content must be ok: %+v", res)
+	}
+	if res = e.CheckContent(context.Background(), "notes.txt", content); res.Action != ActionNone || res.Template != "" {
+		t.Fatalf("no template applies to notes.txt: %+v", res)
+	}
+}
@@ -0,0 +1,43 @@
+package engine
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// Edit replaces a range of bytes of a file.
+type Edit struct {
+	// Offset and Length delimit the replaced bytes.
+	Offset int
+	Length int
+	// Text replaces them.
+	Text []byte
+}
+
+// newEdit returns the single edit turning old into fixed, trimmed to the
+// bytes that change without splitting runes, or nil when fixed is nil or equal to old.
+func newEdit(old, fixed []byte) *Edit {
+	if fixed == nil || bytes.Equal(old, fixed) {
+		return nil
+	}
+	prefix := 0
+	for prefix < len(old) && prefix < len(fixed) && old[prefix] == fixed[prefix] {
+		prefix++
+	}
+	for prefix > 0 && (!runeStart(old, prefix) || !runeStart(fixed, prefix)) {
+		prefix--
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(fixed)-prefix && old[len(old)-1-suffix] == fixed[len(fixed)-1-suffix] {
+		suffix++
+	}
+	for suffix > 0 && (!runeStart(old, len(old)-suffix) || !runeStart(fixed, len(fixed)-suffix)) {
+		suffix--
+	}
+	return &Edit{Offset: prefix, Length: len(old) - prefix - suffix, Text: fixed[prefix : len(fixed)-suffix]}
+}
+
+// runeStart reports whether a rune starts at b[i], or i is the end of b.
+func runeStart(b []byte, i int) bool {
+	return i >= len(b) || utf8.RuneStart(b[i])
+}
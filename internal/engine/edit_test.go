@@ -0,0 +1,92 @@
+package engine
+
+import (
+	"context"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"unicode/utf8"
+)
+
+func applyEdit(content []byte, ed *Edit) []byte {
+	out := append([]byte{}, content[:ed.Offset]...)
+	out = append(out, ed.Text...)
+	return append(out, content[ed.Offset+ed.Length:]...)
+}
+
+func TestNewEdit(t *testing.T) {
+	cases := []struct{ old, fixed string }{
+		{"package main\n", "// Copyright Acme\n\npackage main\n"},
+		{"// Copyright Foo\n\npackage main\n", "// Copyright Acme\n\npackage main\n"},
+		// the edit does not split the runes of é and è
+		{"// © Café\n", "// © Cafè\n"},
+	}
+	for _, c := range cases {
+		ed := newEdit([]byte(c.old), []byte(c.fixed))
+		if got := string(applyEdit([]byte(c.old), ed)); got != c.fixed {
+			t.Fatalf("applying %+v to %q: got %q, want %q", ed, c.old, got, c.fixed)
+		}
+		if !utf8.Valid(ed.Text) || !runeStart([]byte(c.old), ed.Offset) {
+			t.Fatalf("edit splits runes: %+v", ed)
+		}
+	}
+	if newEdit([]byte("x"), nil) != nil || newEdit([]byte("x"), []byte("x")) != nil {
+		t.Fatalf("expected no edit")
+	}
+}
+
+func TestCheck_FixMatchesFixMode(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "tmpl.txt")
+	mustWrite(t, tmpl, []byte("// Copyright Acme\n"))
+	rules := []TemplateRule{{TemplatePath: tmpl, Include: regexp.MustCompile(DefaultIncludeRegex)}}
+	e, err := New(Options{Root: dir, Rules: rules, Git: &fakeGit{touched: true}})
+	if err != nil {
+		t.Fatalf("init engine: %v", err)
+	}
+	for _, content := range []string{
+		"package main\n",
+		// the package doc comment is kept by fix, so it must be by the edit
+		"// Package main does things.\npackage main\n",
+		"\ufeff//go:build linux\r\n\r\npackage main\r\n",
+	} {
+		checked := e.CheckContent(context.Background(), "main.go", []byte(content))
+		fixed := e.FixContent(context.Background(), "main.go", []byte(content))
+		if checked.Fix == nil || fixed.NewContent == nil {
+			t.Fatalf("expected a fix for %q, got: %+v", content, checked)
+		}
+		if got := string(applyEdit([]byte(content), checked.Fix)); got != string(fixed.NewContent) {
+			t.Fatalf("edit of %q gives:\n%q\nfix gives:\n%q", content, got, fixed.NewContent)
+		}
+	}
+}
+
+// countingGit is a fakeGit counting the Touched lookups.
+type countingGit struct {
+	fakeGit
+	touchedCalls int
+}
+
+func (g *countingGit) Touched(ctx context.Context, path string) (bool, error) {
+	g.touchedCalls++
+	return g.fakeGit.Touched(ctx, path)
+}
+
+func TestCheck_ComputesFixInOnePass(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "tmpl.txt")
+	mustWrite(t, tmpl, []byte("// Copyright {{ .YearRange }} Acme\n"))
+	rules := []TemplateRule{{TemplatePath: tmpl, Include: regexp.MustCompile(DefaultIncludeRegex)}}
+	git := &countingGit{fakeGit: fakeGit{created: "2020-01-01", updated: "2020-01-01", touched: true}}
+	e, err := New(Options{Root: dir, Rules: rules, Git: git})
+	if err != nil {
+		t.Fatalf("init engine: %v", err)
+	}
+	res := e.CheckContent(context.Background(), "main.go", []byte("package main\n"))
+	if res.Fix == nil || res.NewContent != nil {
+		t.Fatalf("expected a fix and no new content, got: %+v", res)
+	}
+	if git.touchedCalls != 1 {
+		t.Fatalf("git was asked %d times whether the file is touched, want 1", git.touchedCalls)
+	}
+}
@@ -2,16 +2,17 @@
 package engine
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"errors"
 	"fmt"
-	"io"
-	"os"
+	"io/fs"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
+	"sync"
+	"text/template"
 	"unicode/utf8"
 )
 
@@ -24,6 +25,17 @@ type GitMetadata interface {
 	Touched(ctx context.Context, path string) (bool, error)
 }
 
+// IgnoreChecker is implemented by GitMetadata providers knowing which paths
+// version control ignores. Ignored files and directories are not walked;
+// paths Ignored fails for are reported with the error instead.
+type IgnoreChecker interface {
+	Ignored(ctx context.Context, path string) (bool, error)
+}
+
+// DefaultSkipDirs are the names of the directories not walked when
+// Options.SkipDirs is nil. .git is always skipped.
+var DefaultSkipDirs = []string{"vendor", ".idea", ".vscode", "node_modules"}
+
 // DefaultIncludeRegex aims to match common source code file extensions across languages.
 const DefaultIncludeRegex = `(?i)\.(go|c|h|hpp|hh|cc|cpp|cxx|cs|java|kt|ts|tsx|js|jsx|mjs|cjs|rb|py|rs|php|swift|m|mm|scala|sh|bash|zsh|fish|pl|pm|r|jl|sql|proto|make|mk|cmake|dockerfile|gradle|sbt|groovy|hs|erl|ex|exs|clj|cljs|edn|fs|fsi|fsx|ps1|psm1|vb|vbs|lua|coffee|dart|nim|zig)$`
 
@@ -33,6 +45,30 @@ type TemplateRule struct {
 	Include      *regexp.Regexp
 	Exclude      *regexp.Regexp
 	Content      []byte
+	// CommentStyle forces the comment style used to render a neutral template,
+	// by name or by a key of Options.CommentStyles. Empty means "by file type".
+	CommentStyle string
+	// Forbidden marks a template whose headers must be removed, eg an old
+	// proprietary notice. Forbidden templates are never inserted.
+	Forbidden bool
+	// Replaces lists the paths of deprecated templates: headers matching them
+	// are replaced in place with this template, eg when relicensing.
+	Replaces []string
+
+	// neutral is set when Content is plain text that must be wrapped in comments.
+	neutral bool
+	// tmpl is set when Content is a Go text/template document.
+	tmpl *template.Template
+	// matchers caches the matchers compiled from the template of a loaded rule.
+	matchers *matcherCache
+	// idx is the position of the rule in Options.Rules.
+	idx int
+	// data is set on rendered rules to the data they were rendered with.
+	data *TemplateData
+	// deprecated is set on the templates listed by the Replaces of the rule
+	// at index replacedBy.
+	deprecated bool
+	replacedBy int
 }
 
 // Options describes the options for the engine.
@@ -40,43 +76,168 @@ type Options struct {
 	Root       string
 	Rules      []TemplateRule
 	Force      bool
-	Verbose    bool
 	Git        GitMetadata
 	RespectGit bool
+	// CommentStyles extends the built-in registry. Keys are file extensions
+	// (".foo"), lower-cased base names ("jenkinsfile") or custom style names.
+	CommentStyles map[string]CommentStyle
+	// Variables are exposed to Go text/template headers as {{ .Vars.name }}.
+	Variables map[string]string
+	// Workers is the number of files processed concurrently. Defaults to GOMAXPROCS.
+	Workers int
+	// MaxViolations stops processing once that many files need a header
+	// change or failed. 0 means no limit.
+	MaxViolations int
+	// DryRun computes fixes without writing files, see FileResult.NewContent.
+	DryRun bool
+	// IncludeGenerated processes generated files, which are skipped by default.
+	IncludeGenerated bool
+	// EnforcePlacement reports correct headers found below directives, such
+	// as //go:build, as ActionMove, and fix moves them above. They are
+	// accepted by default.
+	EnforcePlacement bool
+	// SkipDirs lists the directories not walked: base names, or paths relative
+	// to Root when they contain a slash, both accepting filepath.Match patterns.
+	// nil means DefaultSkipDirs.
+	SkipDirs []string
+	// FollowSymlinks processes symbolic links to files, fixing their target.
+	// They are skipped by default.
+	FollowSymlinks bool
+	// FS is the file system files and templates are read from and fixes are
+	// written to. Defaults to OSFS.
+	FS FS
+	// Only restricts processing to these files, eg the files changed by a
+	// pull request. Directories holding none of them are not walked. nil
+	// processes every file. Relative paths, here and among the walked ones,
+	// are resolved against Root.
+	Only []string
 }
 
 // Engine is the main engine for headercheck.
 type Engine struct {
 	opts Options
+	// root is the absolute path of Options.Root.
+	root string
+	// only and onlyDirs hold the absolute paths of the files of Options.Only
+	// and of their ancestor directories, nil when every file is processed.
+	only     map[string]bool
+	onlyDirs map[string]bool
 }
 
 // New creates a new engine.
 func New(opts Options) (*Engine, error) {
-	e := &Engine{opts: Options{Root: opts.Root, Force: opts.Force, Verbose: opts.Verbose, Git: opts.Git, RespectGit: opts.RespectGit}}
+	e := &Engine{opts: Options{Root: opts.Root, Force: opts.Force, Git: opts.Git, RespectGit: opts.RespectGit, Variables: opts.Variables, Workers: opts.Workers, MaxViolations: opts.MaxViolations, DryRun: opts.DryRun, IncludeGenerated: opts.IncludeGenerated, EnforcePlacement: opts.EnforcePlacement, SkipDirs: opts.SkipDirs, FollowSymlinks: opts.FollowSymlinks, FS: opts.FS}}
+	if e.opts.FS == nil {
+		e.opts.FS = OSFS{}
+	}
+	if e.opts.SkipDirs == nil {
+		e.opts.SkipDirs = DefaultSkipDirs
+	}
+	root, err := filepath.Abs(opts.Root)
+	if err != nil {
+		return nil, fmt.Errorf("resolve root: %w", err)
+	}
+	e.root = root
+	if opts.Only != nil {
+		e.only, e.onlyDirs = map[string]bool{}, map[string]bool{}
+		for _, p := range opts.Only {
+			p = e.abs(p)
+			e.only[p] = true
+			for dir := filepath.Dir(p); !e.onlyDirs[dir]; dir = filepath.Dir(dir) {
+				e.onlyDirs[dir] = true
+			}
+		}
+	}
+	if len(opts.CommentStyles) > 0 {
+		e.opts.CommentStyles = make(map[string]CommentStyle, len(opts.CommentStyles))
+		for k, cs := range opts.CommentStyles {
+			e.opts.CommentStyles[strings.ToLower(k)] = cs
+		}
+	}
 	for _, tr := range opts.Rules {
 		if strings.TrimSpace(tr.TemplatePath) == "" {
 			continue
 		}
-		b, err := os.ReadFile(tr.TemplatePath)
+		rule, err := e.loadRule(tr)
 		if err != nil {
-			return nil, fmt.Errorf("read template %s: %w", tr.TemplatePath, err)
+			return nil, err
+		}
+		e.opts.Rules = append(e.opts.Rules, rule)
+		// Deprecated templates are matched with the include/exclude and
+		// comment style of the rule replacing them.
+		for _, path := range tr.Replaces {
+			old, err := e.loadRule(TemplateRule{TemplatePath: path, Include: tr.Include, Exclude: tr.Exclude, CommentStyle: tr.CommentStyle})
+			if err != nil {
+				return nil, err
+			}
+			old.deprecated, old.replacedBy = true, rule.idx
+			e.opts.Rules = append(e.opts.Rules, old)
 		}
-		e.opts.Rules = append(e.opts.Rules, TemplateRule{
-			TemplatePath: tr.TemplatePath,
-			Include:      tr.Include,
-			Exclude:      tr.Exclude,
-			Content:      normalizeNewlines(b),
-		})
 	}
 	return e, nil
 }
 
+// loadRule reads and parses the template of a rule.
+func (e *Engine) loadRule(tr TemplateRule) (TemplateRule, error) {
+	b, err := e.opts.FS.ReadFile(tr.TemplatePath)
+	if err != nil {
+		return TemplateRule{}, fmt.Errorf("read template %s: %w", tr.TemplatePath, err)
+	}
+	if tr.CommentStyle != "" {
+		if _, ok := e.namedCommentStyle(tr.CommentStyle); !ok {
+			return TemplateRule{}, fmt.Errorf("template %s: unknown comment style %q", tr.TemplatePath, tr.CommentStyle)
+		}
+	}
+	content := normalizeNewlines(b)
+	rule := TemplateRule{
+		TemplatePath: tr.TemplatePath,
+		Include:      tr.Include,
+		Exclude:      tr.Exclude,
+		Content:      content,
+		CommentStyle: tr.CommentStyle,
+		Forbidden:    tr.Forbidden,
+		Replaces:     tr.Replaces,
+		neutral:      !isPreCommented(content),
+		matchers:     &matcherCache{},
+		idx:          len(e.opts.Rules),
+	}
+	if isGoTemplate(content) {
+		rule.tmpl, err = parseGoTemplate(tr.TemplatePath, content)
+		if err != nil {
+			return TemplateRule{}, fmt.Errorf("parse template %s: %w", tr.TemplatePath, err)
+		}
+	}
+	return rule, nil
+}
+
 // FileResult describes the action taken or required for a file.
 type FileResult struct {
 	Path    string
 	Action  Action
 	Err     error
 	Warning string
+	// Reason explains why the header is missing or incorrect, in check mode.
+	Reason string
+	// Template is the path of the template the file was checked against.
+	Template string
+	// Header is the header detected in the file, before any fix.
+	Header []byte
+	// Expected is the header rendered from Template for the file.
+	Expected []byte
+	// Line is the 1-based line of Header in the file, or the line where the
+	// header is to be inserted when there is none.
+	Line int
+	// Offset is the byte offset of Header in the file, or the offset where the
+	// header is to be inserted when there is none.
+	Offset int
+	// NewContent is the content of the file after the fix, in fix mode when
+	// the file is changed. It is not written with Options.DryRun.
+	NewContent []byte
+	// Fix is the change fix makes to the original content of the file, in
+	// check and fix modes, nil when the file needs no change.
+	Fix *Edit
+	// Skipped explains why the file was left alone, eg an ignore marker.
+	Skipped string
 }
 
 // Action describes the action taken or required for a file.
@@ -91,155 +252,541 @@ const (
 	ActionReplace Action = "replace"
 	// ActionRemove indicates that the header should be removed for the file.
 	ActionRemove Action = "remove"
+	// ActionMove indicates that the header is correct but must be moved above
+	// the directives of the file.
+	ActionMove Action = "move"
 )
 
-// Process checks and fixes the headers for the given paths.
+// ErrMaxViolations is returned by Process and ProcessFunc when processing
+// stopped after Options.MaxViolations violations.
+var ErrMaxViolations = errors.New("too many violations")
+
+// Process checks and fixes the headers for the given paths. Results keep the
+// order in which files were found. When ctx is canceled, Process stops promptly
+// and returns the results emitted so far along with ctx.Err().
 func (e *Engine) Process(ctx context.Context, paths []string, fix bool) ([]FileResult, error) {
 	var results []FileResult
+	err := e.ProcessFunc(ctx, paths, fix, func(r FileResult) error {
+		results = append(results, r)
+		return nil
+	})
+	return results, err
+}
+
+// ProcessFunc checks and fixes the headers for the given paths and calls fn
+// with the result of each file as soon as it is decided. Files are processed
+// concurrently by Options.Workers workers while fn is called sequentially, in
+// the order in which files were found.
+//
+// Processing stops when fn returns an error, which is then returned, when
+// Options.MaxViolations is reached, in which case ErrMaxViolations is
+// returned, or when ctx is canceled.
+func (e *Engine) ProcessFunc(ctx context.Context, paths []string, fix bool, fn func(FileResult) error) error {
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type job struct {
+		idx int
+		res FileResult
+	}
+	jobs := make(chan job)
+	done := make(chan job)
+
+	var walkErr error
+	go func() {
+		defer close(jobs)
+		idx := 0
+		walkErr = e.walk(ctx, paths, func(r FileResult) error {
+			select {
+			case jobs <- job{idx: idx, res: r}:
+				idx++
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < e.workers(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				if ctx.Err() != nil {
+					return
+				}
+				// walk errors are reported as is
+				if j.res.Err == nil {
+					j.res = e.processFile(ctx, j.res.Path, fix)
+				}
+				select {
+				case done <- j:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	// Results are buffered until all the files found before them are emitted.
+	var err error
+	pending := map[int]FileResult{}
+	next, violations := 0, 0
+	for j := range done {
+		if err != nil {
+			continue
+		}
+		pending[j.idx] = j.res
+		for r, ok := pending[next]; ok; r, ok = pending[next] {
+			delete(pending, next)
+			next++
+			if err = fn(r); err != nil {
+				cancel()
+				break
+			}
+			if r.Err != nil || r.Action != ActionNone {
+				violations++
+				if e.opts.MaxViolations > 0 && violations >= e.opts.MaxViolations {
+					err = ErrMaxViolations
+					cancel()
+					break
+				}
+			}
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+	if err := parent.Err(); err != nil {
+		return err
+	}
+	return walkErr
+}
+
+// workers returns the size of the worker pool.
+func (e *Engine) workers() int {
+	if e.opts.Workers > 0 {
+		return e.opts.Workers
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// walk expands the given paths into the files to process and calls fn for each
+// of them, in walk order. Paths that cannot be read are passed with Err set.
+func (e *Engine) walk(ctx context.Context, paths []string, fn func(FileResult) error) error {
 	for _, p := range paths {
-		info, err := os.Stat(p)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		info, err := e.opts.FS.Stat(p)
 		if err != nil {
-			results = append(results, FileResult{Path: p, Err: err})
+			if err := fn(FileResult{Path: p, Err: err}); err != nil {
+				return err
+			}
 			continue
 		}
-		if info.IsDir() {
-			err = filepath.WalkDir(p, func(path string, d os.DirEntry, err error) error {
+		if !info.IsDir() {
+			if !e.isSelected(p) {
+				continue
+			}
+			if err := fn(FileResult{Path: p}); err != nil {
+				return err
+			}
+			continue
+		}
+		err = walkDir(e.opts.FS, p, func(path string, d fs.DirEntry, err error) error {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			if err != nil {
+				return fn(FileResult{Path: path, Err: err})
+			}
+			// paths given explicitly are walked even when ignored
+			if path != p {
+				ignored, err := e.isIgnored(ctx, path)
 				if err != nil {
-					results = append(results, FileResult{Path: path, Err: err})
-					return nil
+					// report the path rather than check what may be ignored
+					if err := fn(FileResult{Path: path, Err: err}); err != nil {
+						return err
+					}
+					ignored = true
 				}
-				if d.IsDir() {
-					// skip vendor and .git
-					name := d.Name()
-					if name == ".git" || name == "vendor" || name == ".idea" || name == ".vscode" || name == "node_modules" {
+				if ignored {
+					if d.IsDir() {
 						return filepath.SkipDir
 					}
 					return nil
 				}
-				// // Skip template files themselves
-				// for _, tr := range e.opts.Rules {
-				// 	if filepath.Clean(path) == filepath.Clean(tr.TemplatePath) {
-				// 		return nil
-				// 	}
-				// }
-				fr := e.processFile(ctx, path, fix)
-				results = append(results, fr)
+			}
+			if d.IsDir() {
+				if e.isSkippedDir(path) || !e.holdsSelected(path) {
+					return filepath.SkipDir
+				}
 				return nil
-			})
-			if err != nil {
-				return results, err
 			}
-			continue
+			if !e.isSelected(path) {
+				return nil
+			}
+			return fn(FileResult{Path: path})
+		})
+		if err != nil {
+			return err
 		}
-		fr := e.processFile(ctx, p, fix)
-		results = append(results, fr)
 	}
-	return results, nil
+	return nil
 }
 
-// processFile checks and fixes the header for the given path.
-func (e *Engine) processFile(ctx context.Context, path string, fix bool) FileResult {
-	if e.isTemplatePath(path) {
-		return FileResult{Path: path, Action: ActionNone}
+// isSelected reports whether the file is processed per Options.Only.
+func (e *Engine) isSelected(path string) bool {
+	return e.only == nil || e.only[e.abs(path)]
+}
+
+// holdsSelected reports whether the directory may hold files processed per
+// Options.Only.
+func (e *Engine) holdsSelected(dir string) bool {
+	return e.onlyDirs == nil || e.onlyDirs[e.abs(dir)]
+}
+
+// abs resolves a path relative to Options.Root.
+func (e *Engine) abs(path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
 	}
+	return filepath.Join(e.root, path)
+}
 
-	rel := e.relativePath(path)
+// isSkippedDir reports whether the directory is .git or matches
+// Options.SkipDirs.
+func (e *Engine) isSkippedDir(dir string) bool {
+	name := filepath.Base(dir)
+	if name == ".git" {
+		return true
+	}
+	for _, pattern := range e.opts.SkipDirs {
+		target := name
+		if strings.Contains(pattern, "/") {
+			target = e.relativePath(dir)
+			pattern = filepath.FromSlash(strings.Trim(pattern, "/"))
+		}
+		if ok, _ := filepath.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isIgnored reports whether version control ignores the path.
+func (e *Engine) isIgnored(ctx context.Context, path string) (bool, error) {
+	ic, ok := e.opts.Git.(IgnoreChecker)
+	if !ok {
+		return false, nil
+	}
+	return ic.Ignored(ctx, path)
+}
 
+// processFile checks and fixes the header for the given path.
+func (e *Engine) processFile(ctx context.Context, path string, fix bool) FileResult {
 	// If no template applies to this path (by include/exclude), skip early
-	if !e.hasAnyTemplateForPath(rel) {
+	if !e.appliesTo(path) {
 		return FileResult{Path: path, Action: ActionNone}
 	}
 
-	content, err := os.ReadFile(path)
+	if !e.opts.FollowSymlinks {
+		if info, err := e.opts.FS.Lstat(path); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+			return FileResult{Path: path, Action: ActionNone, Skipped: "symbolic link"}
+		}
+	}
+
+	raw, err := e.opts.FS.ReadFile(path)
 	if err != nil {
 		return FileResult{Path: path, Err: err}
 	}
+	res := e.processContent(ctx, path, raw, fix)
+	if res.NewContent == nil || res.Err != nil {
+		return res
+	}
+	if err := e.writeFile(path, res.NewContent); err != nil {
+		res.Err = err
+		res.NewContent = nil
+		res.Fix = nil
+	}
+	return res
+}
+
+// appliesTo reports whether the file is not a template and at least one
+// template applies to it.
+func (e *Engine) appliesTo(path string) bool {
+	return !e.isTemplatePath(path) && e.hasAnyTemplateForPath(e.relativePath(path))
+}
+
+// processContent checks the content of a file and computes its fix in
+// FileResult.NewContent, without reading or writing the file.
+func (e *Engine) processContent(ctx context.Context, path string, raw []byte, fix bool) FileResult {
+	// Headers are detected and rendered without BOM and with LF line endings
+	format := detectTextFormat(raw)
+	content := format.normalize(raw)
+
+	// The fixed content is computed in both modes: checks report the change
+	// fix makes, which may keep comments a plain header replacement would drop
+	res := e.processNormalized(ctx, path, raw, content, format, fix)
+	if res.Err == nil && res.Action != ActionNone {
+		res.Fix = newEdit(raw, res.NewContent)
+	}
+	if !fix {
+		res.NewContent = nil
+	}
+	return res
+}
+
+// processNormalized is processContent on content, the normalized form of raw.
+func (e *Engine) processNormalized(ctx context.Context, path string, raw, content []byte, format textFormat, fix bool) FileResult {
+	rel := e.relativePath(path)
+	if marker, reason, ok := findIgnoreMarker(content); ok {
+		if reason != "" {
+			marker += ": " + reason
+		}
+		return FileResult{Path: path, Action: ActionNone, Skipped: marker}
+	}
+	if !e.opts.IncludeGenerated && isGenerated(content) {
+		return FileResult{Path: path, Action: ActionNone, Skipped: "generated file"}
+	}
 
 	if fr, ok := e.handleNonUTF8File(path, content); !ok {
 		return fr
 	}
 
 	// Render templates for this file and detect current header
-	trulesAll := e.renderTemplates(path)
-	trules := e.filterTemplatesForPath(rel, trulesAll)
-	if len(trules) == 0 {
-		// No applicable template for this file; skip
-		return FileResult{Path: path, Action: ActionNone}
+	trulesAll, err := e.renderTemplates(path, content)
+	if err != nil {
+		return FileResult{Path: path, Err: err}
+	}
+	applicable := e.filterTemplatesForPath(rel, trulesAll)
+	if len(applicable) == 0 {
+		// Applicable templates are all neutral and the file type has no known comment style
+		return FileResult{Path: path, Action: ActionNone, Warning: fmt.Sprintf("no comment style known for %q files, see comment_styles", commentStyleKey(path))}
 	}
-	currentHeader, _, _ := detectHeaderBlock(content)
+	cs := e.commentStyleForRule(applicable[0], path)
+	trules, forbidden, deprecated := splitRules(applicable)
 
+	var res FileResult
+	if tr, start, end, ok := e.findForbiddenHeader(forbidden, content, cs); ok {
+		res = e.handleForbiddenHeader(ctx, path, rel, fix, content, trules, forbidden, deprecated, cs, tr, start, end)
+	} else {
+		res = e.processHeader(ctx, path, rel, fix, content, trules, deprecated, cs)
+	}
+	return restoreResult(res, raw, content, format)
+}
+
+// restoreResult maps a result computed on the normalized content of a file
+// back to its original content.
+func restoreResult(res FileResult, raw, content []byte, format textFormat) FileResult {
+	if format == (textFormat{}) {
+		return res
+	}
+	res.Offset = format.originalOffset(raw, res.Offset)
+	res.Header = format.apply(res.Header)
+	res.Expected = format.apply(res.Expected)
+	if res.NewContent != nil {
+		res.NewContent = format.restore(raw, content, res.NewContent)
+	}
+	return res
+}
+
+// processHeader checks and fixes the header of a file against the templates
+// applicable to it.
+func (e *Engine) processHeader(ctx context.Context, path, rel string, fix bool, content []byte, trules, deprecated []TemplateRule, cs *CommentStyle) FileResult {
 	// Try to find a matching template for the current header
-	matchedIdx := e.findMatchingTemplateIndex(rel, trules, currentHeader)
+	matchedIdx, matchedHeader := e.findMatchingTemplateIndex(rel, trules, content, cs)
 
 	if matchedIdx >= 0 {
-		return e.handleMatchedHeader(ctx, path, fix, currentHeader, trules[matchedIdx], content)
+		return e.handleMatchedHeader(ctx, path, fix, matchedHeader, trules[matchedIdx], content, cs)
+	}
+
+	// Headers of deprecated templates are migrated rather than preserved
+	if res, ok := e.handleDeprecatedHeader(path, content, trules, deprecated, cs); ok {
+		return res
 	}
 
 	// No match with any template
-	return e.handleNoMatch(ctx, path, fix, currentHeader, content, trules)
+	currentHeader, _, _ := detectHeaderBlock(content, cs)
+	if isDocstring(currentHeader, cs) {
+		// a module docstring is documentation, not a header to replace
+		currentHeader = nil
+	}
+	return e.handleNoMatch(ctx, path, fix, currentHeader, content, trules, cs)
 }
 
-// normalizeNewlines converts CRLF to LF
-func normalizeNewlines(b []byte) []byte {
-	return bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n"))
+// splitRules separates the templates to enforce from the forbidden and the
+// deprecated ones.
+func splitRules(trules []TemplateRule) (required, forbidden, deprecated []TemplateRule) {
+	for _, tr := range trules {
+		switch {
+		case tr.deprecated:
+			deprecated = append(deprecated, tr)
+		case tr.Forbidden:
+			forbidden = append(forbidden, tr)
+		default:
+			required = append(required, tr)
+		}
+	}
+	return required, forbidden, deprecated
+}
+
+// findForbiddenHeader looks for a top-of-file comment matching a forbidden
+// template and returns its range, including trailing blank lines. Comments
+// are searched below the shebang, across directives.
+func (e *Engine) findForbiddenHeader(forbidden []TemplateRule, content []byte, cs *CommentStyle) (TemplateRule, int, int, bool) {
+	if len(forbidden) == 0 {
+		return TemplateRule{}, 0, 0, false
+	}
+	var units []commentUnit
+	for pos := skipBlankAndDirectives(content, findShebangEnd(content)); pos < len(content); {
+		next := pos
+		if found := scanCommentUnits(content, pos, cs); len(found) > 0 {
+			units = append(units, found...)
+			next = found[len(found)-1].end
+		}
+		next = skipBlankAndDirectives(content, next)
+		if next <= pos {
+			break
+		}
+		pos = next
+	}
+	for i := range units {
+		for _, tr := range forbidden {
+			n := countCommentUnits(tr.Content, cs)
+			if i+n > len(units) {
+				continue
+			}
+			start, end := units[i].start, skipBlankLines(content, units[i+n-1].end)
+			if e.headerMatches(tr, content[start:end], cs) {
+				return tr, start, end, true
+			}
+		}
+	}
+	return TemplateRule{}, 0, 0, false
 }
 
-// renderTemplates expands variables for a given file path.
-func (e *Engine) renderTemplates(path string) []TemplateRule {
-	// gather variables
-	author, _ := e.opts.Git.Author(path)
-	cr, _ := e.opts.Git.CreationDate(path)
-	lu, _ := e.opts.Git.LastUpdateDate(path)
-	if author == "" {
-		author = "unknown"
+// handleForbiddenHeader reports a forbidden header found at [start, end) and
+// strips every forbidden header before checking the file against the required
+// templates, whose result fix mode reports. Shebangs, directives and other
+// comments are kept.
+func (e *Engine) handleForbiddenHeader(
+	ctx context.Context,
+	path, rel string,
+	fix bool,
+	content []byte,
+	trules, forbidden, deprecated []TemplateRule,
+	cs *CommentStyle,
+	tr TemplateRule,
+	start, end int,
+) FileResult {
+	res := FileResult{
+		Path:     path,
+		Action:   ActionRemove,
+		Reason:   "forbidden header found",
+		Template: tr.TemplatePath,
+		Header:   content[start:end],
+		Line:     lineAt(content, start),
+		Offset:   start,
 	}
-	if cr == "" {
-		cr = ""
+	for ok := true; ok; _, start, end, ok = e.findForbiddenHeader(forbidden, content, cs) {
+		stripped := make([]byte, 0, len(content)-(end-start))
+		stripped = append(stripped, content[:start]...)
+		content = append(stripped, content[end:]...)
 	}
-	if lu == "" {
-		lu = ""
+	if len(trules) > 0 {
+		// the header is replaced when the stripped file misses the required one
+		if next := e.processHeader(ctx, path, rel, fix, content, trules, deprecated, cs); next.Err != nil || next.Action != ActionNone {
+			if !fix {
+				res.NewContent = next.NewContent
+				return res
+			}
+			if next.Err == nil {
+				next.Action = ActionReplace
+			}
+			return next
+		}
 	}
+	res.NewContent = content
+	return res
+}
+
+// lineAt returns the 1-based line number of the byte offset pos.
+func lineAt(content []byte, pos int) int {
+	return bytes.Count(content[:pos], []byte("\n")) + 1
+}
+
+// normalizeNewlines converts CRLF to LF
+func normalizeNewlines(b []byte) []byte {
+	return bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n"))
+}
+
+// renderTemplates expands variables for a given file path and wraps neutral
+// templates in the comment style of the file. Neutral templates are dropped
+// when no comment style is known for the file. content may be nil.
+func (e *Engine) renderTemplates(path string, content []byte) ([]TemplateRule, error) {
+	data := e.templateData(path, content)
 	var out []TemplateRule
 	for _, tr := range e.opts.Rules {
-		s := string(tr.Content)
-		s = strings.ReplaceAll(s, "%author%", author)
-		s = strings.ReplaceAll(s, "%creation_date%", cr)
-		s = strings.ReplaceAll(s, "%last_update_date%", lu)
-		out = append(out, TemplateRule{
-			TemplatePath: tr.TemplatePath,
-			Include:      tr.Include,
-			Exclude:      tr.Exclude,
-			Content:      []byte(s),
-		})
+		if tr.neutral && e.commentStyleForRule(tr, path) == nil {
+			continue
+		}
+		rendered, err := e.renderRule(tr, path, data)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rendered)
+	}
+	return out, nil
+}
+
+// renderRule renders a single rule for a file with the given data.
+func (e *Engine) renderRule(tr TemplateRule, path string, data TemplateData) (TemplateRule, error) {
+	rendered, err := renderContent(tr, data)
+	if err != nil {
+		return TemplateRule{}, err
+	}
+	if tr.neutral {
+		if cs := e.commentStyleForRule(tr, path); cs != nil {
+			rendered = renderComment(rendered, cs)
+		}
 	}
-	return out
+	return TemplateRule{
+		TemplatePath: tr.TemplatePath,
+		Include:      tr.Include,
+		Exclude:      tr.Exclude,
+		Content:      rendered,
+		CommentStyle: tr.CommentStyle,
+		Forbidden:    tr.Forbidden,
+		Replaces:     tr.Replaces,
+		neutral:      tr.neutral,
+		tmpl:         tr.tmpl,
+		idx:          tr.idx,
+		data:         &data,
+		deprecated:   tr.deprecated,
+		replacedBy:   tr.replacedBy,
+	}, nil
 }
 
-// headerSemanticallyMatches compares two headers ignoring dynamic variable values by masking variables.
-func headerSemanticallyMatches(existing, expected []byte) bool {
+// headerSemanticallyMatches compares two rendered headers, ignoring comment
+// markers and whitespace, so a line comment header matches the same text
+// written as a block comment. Variable values must be equal: use the matcher
+// derived from the template to accept other values.
+func headerSemanticallyMatches(existing, expected []byte, cs *CommentStyle) bool {
 	if len(existing) == 0 || len(expected) == 0 {
 		return false
 	}
-	// Mask variable sections by replacing sequences matching typical date/hash/email with placeholders
-	sanitize := func(s []byte) []byte {
-		text := string(s)
-		// dates like 2024-07-31, 2024/07/31, 31-07-2024 etc.
-		text = regexp.MustCompile(`\b\d{4}[-/]?\d{2}[-/]?\d{2}\b`).ReplaceAllString(text, "<DATE>")
-		// time
-		text = regexp.MustCompile(`\b\d{2}:\d{2}:\d{2}\b`).ReplaceAllString(text, "<TIME>")
-		// email
-		text = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`).ReplaceAllString(text, "<EMAIL>")
-		// hex hashes
-		text = regexp.MustCompile(`\b[0-9a-fA-F]{7,40}\b`).ReplaceAllString(text, "<HASH>")
-		// years
-		text = regexp.MustCompile(`\b(19|20)\d{2}\b`).ReplaceAllString(text, "<YEAR>")
-		// collapse multiple spaces
-		text = regexp.MustCompile(`\s+`).ReplaceAllString(text, " ")
-		return []byte(strings.TrimSpace(text))
-	}
-	a := sanitize(existing)
-	b := sanitize(expected)
-	return bytes.Equal(a, b)
+	a := normalizeHeaderText(stripCommentMarkers(existing, cs))
+	b := normalizeHeaderText(stripCommentMarkers(expected, cs))
+	return a == b
 }
 
 // headersStructurallyEqual considers headers equal if they only differ by trailing
@@ -251,53 +798,33 @@ func headersStructurallyEqual(a, b []byte) bool {
 	return bytes.Equal(aa, bb)
 }
 
-// detectHeaderBlock extracts the leading header comment block including shebang handling.
-func detectHeaderBlock(content []byte) (header []byte, start int, end int) {
+// detectHeaderBlock extracts the leading header comment, ie the first comment
+// unit (a run of line comments or one complete block comment) found after the
+// shebang and directives, including its trailing blank lines.
+// Comments are recognized in the given style (may be nil for the default styles).
+func detectHeaderBlock(content []byte, cs *CommentStyle) (header []byte, start int, end int) {
+	return detectHeaderUnits(content, cs, 1)
+}
+
+// detectHeaderUnits is like detectHeaderBlock but spans up to n comment units,
+// for templates made of several comments separated by blank lines.
+func detectHeaderUnits(content []byte, cs *CommentStyle, n int) (header []byte, start int, end int) {
 	// Start after shebang and skip directives to detect existing header block
 	pos := findShebangEnd(content)
 	pos = skipBlankAndDirectives(content, pos)
 
-	// Collect contiguous header comment block
-	r := bufio.NewReader(bytes.NewReader(content[pos:]))
-	var buf bytes.Buffer
-	for {
-		line, err := r.ReadString('\n')
-		if err != nil && !errors.Is(err, io.EOF) {
-			break
-		}
-		trimmed := strings.TrimSpace(line)
-		if trimmed != "" && !isLineComment(line) {
-			break
-		}
-		// Exclude directive lines from header
-		if isGoPreambleDirective(line) {
-			break
-		}
-		buf.WriteString(line)
-		if errors.Is(err, io.EOF) {
-			break
-		}
-	}
-	if buf.Len() == 0 {
+	units := scanCommentUnits(content, pos, cs)
+	if len(units) == 0 {
 		return nil, 0, 0
 	}
-	headerBytes := buf.Bytes()
-	return headerBytes, pos, pos + len(headerBytes)
-}
-
-func isLineComment(line string) bool {
-	s := strings.TrimSpace(line)
-	if s == "" {
-		return true
-	}
-	if strings.HasPrefix(s, "//") || strings.HasPrefix(s, "#") || strings.HasPrefix(s, ";") {
-		return true
+	if n < 1 {
+		n = 1
 	}
-	// block comment start or end lines are counted as comments too
-	if strings.HasPrefix(s, "/*") || strings.HasPrefix(s, "*") || strings.HasPrefix(s, "*/") {
-		return true
+	if n > len(units) {
+		n = len(units)
 	}
-	return false
+	end = skipBlankLines(content, units[n-1].end)
+	return content[pos:end], pos, end
 }
 
 // isGoPreambleDirective reports whether the line is a Go-specific
@@ -399,131 +926,41 @@ func insertHeader(content []byte, header []byte) []byte {
 // upsertHeaderBeforeDirectives ensures the given header exists below the shebang
 // and before any Go preamble directives. If a header already exists, it will be
 // replaced and any directive lines will be kept (and relocated below the header
-// if they were above it).
-func upsertHeaderBeforeDirectives(content []byte, header []byte, preserveExisting bool) []byte {
-	// Extract existing header block
-	existing, start, end := detectHeaderBlock(content)
-	shebangEnd := findShebangEnd(content)
+// if they were above it). The existing header spans as many comment units as the
+// new one, so block comments are always removed as a whole.
+func upsertHeaderBeforeDirectives(content []byte, header []byte, cs *CommentStyle, preserveExisting bool) []byte {
+	// Remove the existing header block, optionally preserving it to relocate it
+	// below the new header.
+	var preservedHeader []byte
+	if existing, start, end := detectHeaderUnits(content, cs, countCommentUnits(header, cs)); len(existing) > 0 {
+		if preserveExisting {
+			preservedHeader = append([]byte(nil), existing...)
+		}
+		var buf bytes.Buffer
+		buf.Write(content[:start])
+		buf.Write(content[end:])
+		content = buf.Bytes()
+	}
 
 	// Split content into three parts: shebang, middle, tail
+	shebangEnd := findShebangEnd(content)
 	head := content[:shebangEnd]
 	middle := content[shebangEnd:]
 
 	// From middle, capture any leading directives and blanks
 	// so we can place header before them
-	dirStart := 0
-	for dirStart < len(middle) {
-		nl := bytes.IndexByte(middle[dirStart:], '\n')
-		lineEnd := len(middle)
-		if nl >= 0 {
-			lineEnd = dirStart + nl + 1
-		}
-		line := string(middle[dirStart:lineEnd])
-		trimmed := strings.TrimSpace(line)
-		if trimmed == "" || isGoPreambleDirective(line) {
-			dirStart = lineEnd
-			if nl < 0 {
-				break
-			}
-			continue
-		}
-		break
-	}
-	directivesAndBlanks := middle[:dirStart]
+	dirEnd := skipBlankAndDirectives(middle, 0)
+	directivesAndBlanks := middle[:dirEnd]
 
-	// Next, capture top-of-file non-directive comment block (and its trailing blanks)
-	// that immediately follows the directives OR begins the file when no directives exist.
-	// This block should be moved below the directives, keeping it below the header.
-	commEnd := dirStart
-	for commEnd < len(middle) {
-		nl := bytes.IndexByte(middle[commEnd:], '\n')
-		lineEnd := len(middle)
-		if nl >= 0 {
-			lineEnd = commEnd + nl + 1
-		}
-		line := string(middle[commEnd:lineEnd])
-		trimmed := strings.TrimSpace(line)
-		if trimmed == "" {
-			commEnd = lineEnd
-			if nl < 0 {
-				break
-			}
-			continue
-		}
-		// treat any comment line as top-of-file comments, but stop before package decl or code
-		if strings.HasPrefix(trimmed, "//") || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "/*") || strings.HasPrefix(trimmed, "*") {
-			commEnd = lineEnd
-			if nl < 0 {
-				break
-			}
-			continue
-		}
-		break
+	// Next, capture top-of-file comments that immediately follow the directives
+	// OR begin the file when no directives exist. They are kept below the header.
+	commEnd := dirEnd
+	if units := scanCommentUnits(middle, dirEnd, cs); len(units) > 0 {
+		commEnd = units[len(units)-1].end
 	}
-	topComments := middle[dirStart:commEnd]
+	topComments := middle[dirEnd:commEnd]
 	rest := middle[commEnd:]
 
-	// If an existing header was detected and it lies within head+directives region,
-	// remove it prior to re-inserting. Optionally preserve the removed block to
-	// relocate it below the header.
-	var preservedHeader []byte
-	if len(existing) > 0 && start >= shebangEnd {
-		// delete existing header range
-		if preserveExisting {
-			preservedHeader = make([]byte, len(existing))
-			copy(preservedHeader, existing)
-		}
-		var buf bytes.Buffer
-		buf.Write(content[:start])
-		buf.Write(content[end:])
-		content = buf.Bytes()
-		// recompute pieces
-		shebangEnd = findShebangEnd(content)
-		head = content[:shebangEnd]
-		middle = content[shebangEnd:]
-		// recompute directives
-		dirStart = 0
-		for dirStart < len(middle) {
-			nl := bytes.IndexByte(middle[dirStart:], '\n')
-			lineEnd := len(middle)
-			if nl >= 0 {
-				lineEnd = dirStart + nl + 1
-			}
-			line := string(middle[dirStart:lineEnd])
-			trimmed := strings.TrimSpace(line)
-			if trimmed == "" || isGoPreambleDirective(line) {
-				dirStart = lineEnd
-				if nl < 0 {
-					break
-				}
-				continue
-			}
-			break
-		}
-		directivesAndBlanks = middle[:dirStart]
-		// recompute top-of-file comments block
-		commEnd = dirStart
-		for commEnd < len(middle) {
-			nl := bytes.IndexByte(middle[commEnd:], '\n')
-			lineEnd := len(middle)
-			if nl >= 0 {
-				lineEnd = commEnd + nl + 1
-			}
-			line := string(middle[commEnd:lineEnd])
-			trimmed := strings.TrimSpace(line)
-			if trimmed == "" || isLineComment(line) {
-				commEnd = lineEnd
-				if nl < 0 {
-					break
-				}
-				continue
-			}
-			break
-		}
-		topComments = middle[dirStart:commEnd]
-		rest = middle[commEnd:]
-	}
-
 	var out bytes.Buffer
 	out.Write(head)
 	// Ensure exactly one blank line between shebang and header (if shebang present)
@@ -541,17 +978,19 @@ func upsertHeaderBeforeDirectives(content []byte, header []byte, preserveExistin
 		out.Write(trimmedDir)
 		out.WriteString("\n\n")
 	}
-	// Any other top-of-file comments moved after directives
-	if trimmedTop := bytes.Trim(topComments, "\n\r"); len(trimmedTop) > 0 {
-		out.Write(trimmedTop)
-		out.WriteString("\n\n")
-	}
 	// If we preserved an existing header (non-template comments), append it as well
 	if len(bytes.TrimSpace(preservedHeader)) > 0 {
 		out.Write(bytes.Trim(preservedHeader, "\n\r"))
 		out.WriteString("\n\n")
 	}
-	out.Write(rest)
+	// Any other top-of-file comments moved after directives. They keep their
+	// own spacing with the code, eg a Go package doc comment stays attached.
+	if trimmedTop := bytes.TrimLeft(topComments, "\n\r"); len(trimmedTop) > 0 {
+		out.Write(trimmedTop)
+		out.Write(rest)
+		return out.Bytes()
+	}
+	out.Write(bytes.TrimLeft(rest, "\n\r"))
 	return out.Bytes()
 }
 
@@ -577,7 +1016,13 @@ func (e *Engine) relativePath(path string) string {
 // hasAnyTemplateForPath checks if at least one template rule applies to a given relative path
 // according to include/exclude patterns.
 func (e *Engine) hasAnyTemplateForPath(rel string) bool {
-	for _, tr := range e.opts.Rules {
+	return AnyRuleApplies(e.opts.Rules, rel)
+}
+
+// AnyRuleApplies reports whether the include/exclude patterns of at least one
+// rule accept a path relative to the root.
+func AnyRuleApplies(rules []TemplateRule, rel string) bool {
+	for _, tr := range rules {
 		if tr.Exclude != nil && tr.Exclude.MatchString(rel) {
 			continue
 		}
@@ -601,7 +1046,10 @@ func (e *Engine) handleNonUTF8File(path string, content []byte) (FileResult, boo
 	return FileResult{Path: path, Action: ActionNone}, false
 }
 
-func (e *Engine) findMatchingTemplateIndex(rel string, trules []TemplateRule, currentHeader []byte) int {
+// findMatchingTemplateIndex returns the index of the first template matching the
+// current header of the file, along with that header. The header spans as many
+// comment units as the template.
+func (e *Engine) findMatchingTemplateIndex(rel string, trules []TemplateRule, content []byte, cs *CommentStyle) (int, []byte) {
 	for i, tr := range trules {
 		if tr.Exclude != nil && tr.Exclude.MatchString(rel) {
 			continue
@@ -609,14 +1057,12 @@ func (e *Engine) findMatchingTemplateIndex(rel string, trules []TemplateRule, cu
 		if tr.Include != nil && !tr.Include.MatchString(rel) {
 			continue
 		}
-		if headerSemanticallyMatches(currentHeader, tr.Content) {
-			if e.opts.Verbose {
-				fmt.Printf("header matched template for %s\n", rel)
-			}
-			return i
+		currentHeader, _, _ := detectHeaderUnits(content, cs, countCommentUnits(tr.Content, cs))
+		if e.headerMatches(tr, currentHeader, cs) {
+			return i, currentHeader
 		}
 	}
-	return -1
+	return -1, nil
 }
 
 // filterTemplatesForPath returns only the templates whose include/exclude accept the given relative path.
@@ -638,8 +1084,17 @@ func (e *Engine) shouldSkipDueToGit(ctx context.Context, path string) bool {
 	if !e.opts.RespectGit || e.opts.Git == nil {
 		return false
 	}
+	return !e.isTouched(ctx, path)
+}
+
+// isTouched reports whether the file has pending changes. Without git
+// metadata, every file is considered touched.
+func (e *Engine) isTouched(ctx context.Context, path string) bool {
+	if e.opts.Git == nil {
+		return true
+	}
 	touched, _ := e.opts.Git.Touched(ctx, path)
-	return !touched
+	return touched
 }
 
 func (e *Engine) handleMatchedHeader(
@@ -649,53 +1104,105 @@ func (e *Engine) handleMatchedHeader(
 	currentHeader []byte,
 	rendered TemplateRule,
 	content []byte,
+	cs *CommentStyle,
 ) FileResult {
+	_, start, _ := detectHeaderUnits(content, cs, countCommentUnits(rendered.Content, cs))
+	res := FileResult{Path: path, Action: ActionNone, Template: rendered.TemplatePath, Header: currentHeader, Expected: rendered.Content, Line: lineAt(content, start), Offset: start}
+	// Headers go above directives, only the shebang may precede them
+	misplaced := e.opts.EnforcePlacement && start > skipBlankLines(content, findShebangEnd(content))
 	if !fix {
-		return FileResult{Path: path, Action: ActionNone}
-	}
-	if bytes.Equal(currentHeader, rendered.Content) {
-		return FileResult{Path: path, Action: ActionNone}
+		// checks only report misplaced headers, along with the move fix makes
+		if !misplaced {
+			return res
+		}
+		res.Action = ActionMove
+		res.Reason = "header must be placed above directives"
 	}
-	if headersStructurallyEqual(currentHeader, rendered.Content) {
-		return FileResult{Path: path, Action: ActionNone}
+	if usesYearRange(e.opts.Rules[rendered.idx]) && e.isTouched(ctx, path) {
+		var err error
+		if rendered, err = e.withCurrentYear(rendered, path); err != nil {
+			res.Err = err
+			return res
+		}
+		if fix {
+			res.Expected = rendered.Content
+		}
 	}
-	// If the only differences are variable-like and the file hasn't been touched,
-	// skip updates to avoid churn. Otherwise, still update to refresh variables.
-	if headerSemanticallyMatches(currentHeader, rendered.Content) && e.shouldSkipDueToGit(ctx, path) {
-		return FileResult{Path: path, Action: ActionNone}
+	if !misplaced {
+		if bytes.Equal(currentHeader, rendered.Content) {
+			return res
+		}
+		if headersStructurallyEqual(currentHeader, rendered.Content) {
+			return res
+		}
+		// If the only differences are variable-like and the file hasn't been touched,
+		// skip updates to avoid churn. Otherwise, still update to refresh variables.
+		if e.headerMatches(rendered, currentHeader, cs) && e.shouldSkipDueToGit(ctx, path) {
+			return res
+		}
+	} else if e.shouldSkipDueToGit(ctx, path) {
+		// keep the current rendering, only move it
+		rendered.Content = append(bytes.TrimRight(currentHeader, "\r\n"), '\n')
 	}
-	// Reorder so that header is after shebang and before any directives
-	nb := upsertHeaderBeforeDirectives(content, rendered.Content, true)
-	if err := os.WriteFile(path, nb, 0o666); err != nil {
-		return FileResult{Path: path, Err: err}
+	// Reorder so that header is after shebang and before any directives. The
+	// current header is an outdated rendering of the template: drop it.
+	res.NewContent = upsertHeaderBeforeDirectives(content, rendered.Content, cs, false)
+	res.Action = ActionReplace
+	if misplaced {
+		res.Action = ActionMove
 	}
-	return FileResult{Path: path, Action: ActionReplace}
+	return res
 }
 
 func (e *Engine) handleNoMatch(
-	_ context.Context,
+	ctx context.Context,
 	path string,
 	fix bool,
 	currentHeader []byte,
 	content []byte,
 	trules []TemplateRule,
+	cs *CommentStyle,
 ) FileResult {
 	if len(trules) == 0 {
 		return FileResult{Path: path, Action: ActionNone}
 	}
-	if fix {
-		nb := upsertHeaderBeforeDirectives(content, trules[0].Content, true)
-		action := ActionInsert
-		if len(currentHeader) > 0 {
-			action = ActionReplace
+	// A header laid out as a template, with only variable values not
+	// matching, is an outdated rendering of it: it is replaced in place.
+	// Other headers are kept below the new one.
+	tr, outdated := trules[0], false
+	for _, candidate := range trules {
+		existing, _, _ := detectHeaderUnits(content, cs, countCommentUnits(candidate.Content, cs))
+		if len(existing) > 0 && e.sameLayout(candidate, existing, cs) {
+			tr, outdated = candidate, true
+			break
 		}
-		if err := os.WriteFile(path, nb, 0o666); err != nil {
+	}
+	existing, start, _ := detectHeaderUnits(content, cs, countCommentUnits(tr.Content, cs))
+	// The header of a touched file, new files without history included, is
+	// committed this year
+	expected := tr
+	if usesYearRange(e.opts.Rules[tr.idx]) && e.isTouched(ctx, path) {
+		var err error
+		if expected, err = e.withCurrentYear(tr, path); err != nil {
 			return FileResult{Path: path, Err: err}
 		}
-		return FileResult{Path: path, Action: action}
+	}
+	res := FileResult{Path: path, Action: ActionInsert, Template: tr.TemplatePath, Header: existing, Expected: expected.Content}
+	if len(currentHeader) > 0 {
+		res.Action = ActionReplace
+	} else {
+		// new headers go right below the shebang
+		res.Header, start = nil, findShebangEnd(content)
+	}
+	res.Line, res.Offset = lineAt(content, start), start
+	res.NewContent = upsertHeaderBeforeDirectives(content, expected.Content, cs, !outdated)
+	if fix {
+		return res
 	}
 	if len(currentHeader) > 0 {
-		return FileResult{Path: path, Action: ActionReplace}
+		res.Reason = e.explainMismatch(tr, existing, cs)
+	} else {
+		res.Reason = "no header found"
 	}
-	return FileResult{Path: path, Action: ActionInsert}
+	return res
 }
@@ -3,6 +3,8 @@ package engine
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -34,7 +36,7 @@ func TestNormalizeNewlines(t *testing.T) {
 
 func TestDetectHeaderBlock_WithShebang(t *testing.T) {
 	content := []byte("#!/usr/bin/env bash\n# header line\n\necho hi\n")
-	hdr, start, end := detectHeaderBlock(content)
+	hdr, start, end := detectHeaderBlock(content, nil)
 	if !bytes.HasPrefix(content, []byte("#!/usr/bin/env bash\n")) {
 		t.Fatalf("invalid test content")
 	}
@@ -59,7 +61,7 @@ func TestInsertHeader_PreservesShebang(t *testing.T) {
 
 func TestReplaceHeader_EnsuresOneBlankLine(t *testing.T) {
 	content := []byte("// old\n\npackage x\n")
-	hdr, s, e := detectHeaderBlock(content)
+	hdr, s, e := detectHeaderBlock(content, nil)
 	if len(hdr) == 0 {
 		t.Fatalf("expected header detected")
 	}
@@ -261,6 +263,37 @@ func TestProcess_InsertWhenMissing(t *testing.T) {
 	}
 }
 
+func TestProcess_LegacyAuthorDefaultsToUnknown(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "tmpl.txt")
+	mustWrite(t, tmpl, []byte("// Header\n// Author: %author%\n"))
+	src := filepath.Join(dir, "hello.go")
+	content := "// Header\n// Author: unknown\n\npackage main\n"
+	mustWrite(t, src, []byte(content))
+	missing := filepath.Join(dir, "other.go")
+	mustWrite(t, missing, []byte("package main\n"))
+
+	// no git history: headers written by earlier versions are kept
+	rules := []TemplateRule{{TemplatePath: tmpl, Include: regexp.MustCompile(DefaultIncludeRegex)}}
+	e, err := New(Options{Root: dir, Rules: rules, Git: &fakeGit{touched: true}})
+	if err != nil {
+		t.Fatalf("init engine: %v", err)
+	}
+	res, err := e.Process(context.Background(), []string{src, missing}, true)
+	if err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	if res[0].Action != ActionNone {
+		t.Fatalf("expected no change, got: %+v", res[0])
+	}
+	if got := string(mustRead(t, src)); got != content {
+		t.Fatalf("header rewritten: %q", got)
+	}
+	if got := string(mustRead(t, missing)); got != content {
+		t.Fatalf("unexpected inserted header: %q", got)
+	}
+}
+
 func TestProcess_ReplaceWhenDifferent(t *testing.T) {
 	dir := t.TempDir()
 	tmpl := filepath.Join(dir, "tmpl.txt")
@@ -380,3 +413,199 @@ func mustRead(t *testing.T, path string) []byte {
 	}
 	return b
 }
+
+func TestProcess_WorkersKeepWalkOrder(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "tmpl.txt")
+	mustWrite(t, tmpl, []byte("// Copyright Acme\n"))
+	for i := 0; i < 50; i++ {
+		sub := filepath.Join(dir, fmt.Sprintf("pkg%02d", i%7))
+		if err := os.MkdirAll(sub, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		content := "package x\n"
+		if i%3 == 0 {
+			content = "// Copyright Acme\n\npackage x\n"
+		}
+		mustWrite(t, filepath.Join(sub, fmt.Sprintf("f%02d.go", i)), []byte(content))
+	}
+
+	rules := []TemplateRule{{TemplatePath: tmpl, Include: regexp.MustCompile(DefaultIncludeRegex)}}
+	var want []FileResult
+	for _, workers := range []int{1, 8} {
+		e, _ := New(Options{Root: dir, Rules: rules, Git: &fakeGit{touched: true}, Workers: workers})
+		res, err := e.Process(context.Background(), []string{dir}, false)
+		if err != nil {
+			t.Fatalf("process error: %v", err)
+		}
+		if want == nil {
+			want = res
+			continue
+		}
+		if len(res) != len(want) {
+			t.Fatalf("expected %d results, got %d", len(want), len(res))
+		}
+		for i := range res {
+			if res[i].Path != want[i].Path || res[i].Action != want[i].Action {
+				t.Fatalf("result %d differs with %d workers: %+v vs %+v", i, workers, res[i], want[i])
+			}
+		}
+	}
+}
+
+func TestProcess_CanceledContext(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "tmpl.txt")
+	mustWrite(t, tmpl, []byte("// Copyright Acme\n"))
+	src := filepath.Join(dir, "main.go")
+	mustWrite(t, src, []byte("package main\n"))
+
+	rules := []TemplateRule{{TemplatePath: tmpl, Include: regexp.MustCompile(DefaultIncludeRegex)}}
+	e, _ := New(Options{Root: dir, Rules: rules, Git: &fakeGit{touched: true}, Workers: 2})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	res, err := e.Process(ctx, []string{dir}, true)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if len(res) != 0 {
+		t.Fatalf("expected no result, got %+v", res)
+	}
+	if got := string(mustRead(t, src)); got != "package main\n" {
+		t.Fatalf("file must not be modified, got:\n%s", got)
+	}
+}
+
+func TestProcessFunc_StreamsInOrderAndStops(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "tmpl.txt")
+	mustWrite(t, tmpl, []byte("// Copyright Acme\n"))
+	for i := 0; i < 20; i++ {
+		mustWrite(t, filepath.Join(dir, fmt.Sprintf("f%02d.go", i)), []byte("package x\n"))
+	}
+	rules := []TemplateRule{{TemplatePath: tmpl, Include: regexp.MustCompile(DefaultIncludeRegex)}}
+	e, _ := New(Options{Root: dir, Rules: rules, Git: &fakeGit{touched: true}, Workers: 4})
+
+	var seen []string
+	errStop := errors.New("stop")
+	err := e.ProcessFunc(context.Background(), []string{dir}, false, func(r FileResult) error {
+		seen = append(seen, filepath.Base(r.Path))
+		if len(seen) == 5 {
+			return errStop
+		}
+		return nil
+	})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("expected callback error, got %v", err)
+	}
+	if want := []string{"f00.go", "f01.go", "f02.go", "f03.go", "f04.go"}; strings.Join(seen, ",") != strings.Join(want, ",") {
+		t.Fatalf("got %v want %v", seen, want)
+	}
+}
+
+func TestProcess_MaxViolations(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "tmpl.txt")
+	mustWrite(t, tmpl, []byte("// Copyright Acme\n"))
+	for i := 0; i < 10; i++ {
+		content := "package x\n"
+		if i%2 == 0 {
+			content = "// Copyright Acme\n\npackage x\n"
+		}
+		mustWrite(t, filepath.Join(dir, fmt.Sprintf("f%02d.go", i)), []byte(content))
+	}
+	rules := []TemplateRule{{TemplatePath: tmpl, Include: regexp.MustCompile(DefaultIncludeRegex)}}
+	e, _ := New(Options{Root: dir, Rules: rules, Git: &fakeGit{touched: true}, MaxViolations: 2})
+	res, err := e.Process(context.Background(), []string{dir}, false)
+	if !errors.Is(err, ErrMaxViolations) {
+		t.Fatalf("expected ErrMaxViolations, got %v", err)
+	}
+	// tmpl.txt is skipped as a template file and comes last
+	if len(res) != 4 || res[3].Action != ActionInsert || filepath.Base(res[3].Path) != "f03.go" {
+		t.Fatalf("expected to stop at the second violation, got %+v", res)
+	}
+}
+
+func TestProcess_ReportsTemplateAndHeaders(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "tmpl.txt")
+	mustWrite(t, tmpl, []byte("// wanted\n"))
+	src := filepath.Join(dir, "hello.go")
+	mustWrite(t, src, []byte("// old\n\npackage main\n"))
+
+	rules := []TemplateRule{{TemplatePath: tmpl, Include: regexp.MustCompile(DefaultIncludeRegex)}}
+	e, _ := New(Options{Root: dir, Rules: rules, Git: &fakeGit{touched: true}})
+	res, err := e.Process(context.Background(), []string{src}, false)
+	if err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	r := res[0]
+	if r.Template != tmpl || string(r.Header) != "// old\n\n" || string(r.Expected) != "// wanted\n" {
+		t.Fatalf("unexpected result: template=%q header=%q expected=%q", r.Template, r.Header, r.Expected)
+	}
+}
+
+func TestProcess_MisplacedHeaderIsMoved(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "tmpl.txt")
+	mustWrite(t, tmpl, []byte("// Updated: %last_update_date%\n"))
+	src := filepath.Join(dir, "hello.go")
+	mustWrite(t, src, []byte("//go:build go1.20\n\n// Updated: 2020-01-01\n\npackage main\n"))
+
+	rules := []TemplateRule{{TemplatePath: tmpl, Include: regexp.MustCompile(DefaultIncludeRegex)}}
+	// Misplaced headers are accepted by default
+	lenient, _ := New(Options{Root: dir, Rules: rules, Git: &fakeGit{updated: "2024-02-02"}, RespectGit: true})
+	res, err := lenient.Process(context.Background(), []string{src}, false)
+	if err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	if res[0].Action != ActionNone {
+		t.Fatalf("expected misplaced header to be accepted, got: %+v", res[0])
+	}
+
+	e, _ := New(Options{Root: dir, Rules: rules, Git: &fakeGit{updated: "2024-02-02"}, RespectGit: true, EnforcePlacement: true})
+	res, err = e.Process(context.Background(), []string{src}, false)
+	if err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	if res[0].Action != ActionMove || res[0].Line != 3 || res[0].Offset != 19 {
+		t.Fatalf("expected move at line 3, got: %+v", res[0])
+	}
+
+	// The file is not touched: the header is moved as is
+	if _, err := e.Process(context.Background(), []string{src}, true); err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	want := "// Updated: 2020-01-01\n\n//go:build go1.20\n\npackage main\n"
+	if got := string(mustRead(t, src)); got != want {
+		t.Fatalf("GOT:\n%s\nWANT:\n%s", got, want)
+	}
+	res, _ = e.Process(context.Background(), []string{src}, false)
+	if res[0].Action != ActionNone {
+		t.Fatalf("expected no action after move, got: %+v", res[0])
+	}
+}
+
+func TestProcess_DryRunProposesContent(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "tmpl.txt")
+	mustWrite(t, tmpl, []byte("// wanted\n"))
+	src := filepath.Join(dir, "hello.go")
+	mustWrite(t, src, []byte("//go:build linux\n\npackage main\n"))
+
+	rules := []TemplateRule{{TemplatePath: tmpl, Include: regexp.MustCompile(DefaultIncludeRegex)}}
+	e, _ := New(Options{Root: dir, Rules: rules, Git: &fakeGit{touched: true}, DryRun: true})
+	res, err := e.Process(context.Background(), []string{src}, true)
+	if err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	if res[0].Action != ActionInsert {
+		t.Fatalf("expected insert, got: %+v", res[0])
+	}
+	if got, want := string(res[0].NewContent), "// wanted\n\n//go:build linux\n\npackage main\n"; got != want {
+		t.Fatalf("GOT:\n%s\nWANT:\n%s", got, want)
+	}
+	if got := string(mustRead(t, src)); got != "//go:build linux\n\npackage main\n" {
+		t.Fatalf("dry run must not write, got:\n%s", got)
+	}
+}
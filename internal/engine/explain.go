@@ -0,0 +1,115 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+)
+
+// Kinds of template rules, see RuleExplanation.
+const (
+	RuleRequired   = "required"
+	RuleForbidden  = "forbidden"
+	RuleDeprecated = "deprecated"
+)
+
+// RuleExplanation tells how a template rule applies to a file.
+type RuleExplanation struct {
+	TemplatePath string
+	// Kind is RuleRequired, RuleForbidden or RuleDeprecated.
+	Kind string
+	// Applies tells whether the include and exclude patterns accept the file,
+	// Reason tells why.
+	Applies bool
+	Reason  string
+	// Rendered is the template rendered for the file, nil when the rule does
+	// not apply or the file has no known comment style.
+	Rendered []byte
+	// Matches tells whether the current header of the file matches the
+	// rendered template.
+	Matches bool
+}
+
+// Explanation tells how the engine handles a file, see Explain.
+type Explanation struct {
+	Path string
+	// CommentStyle is the name of the comment style of the file, empty when
+	// unknown.
+	CommentStyle string
+	Rules        []RuleExplanation
+	// Result is the result of checking the file.
+	Result FileResult
+}
+
+// Explain tells which template rules apply to the file, how they render and
+// which ones match its current header, along with the result of checking it.
+// The file is not changed.
+func (e *Engine) Explain(ctx context.Context, path string) (Explanation, error) {
+	raw, err := e.opts.FS.ReadFile(path)
+	if err != nil {
+		return Explanation{}, err
+	}
+	content := detectTextFormat(raw).normalize(raw)
+	rel := e.relativePath(path)
+
+	exp := Explanation{Path: path}
+	if cs := e.commentStyleForPath(path); cs != nil {
+		exp.CommentStyle = cs.Name
+	}
+	rendered, err := e.renderTemplates(path, content)
+	if err != nil {
+		return Explanation{}, err
+	}
+	byIdx := make(map[int]TemplateRule, len(rendered))
+	for _, tr := range rendered {
+		byIdx[tr.idx] = tr
+	}
+
+	for _, tr := range e.opts.Rules {
+		re := RuleExplanation{TemplatePath: tr.TemplatePath, Kind: ruleKind(tr)}
+		re.Applies, re.Reason = ruleApplies(tr, rel)
+		if r, ok := byIdx[tr.idx]; ok && re.Applies {
+			cs := e.commentStyleForRule(r, path)
+			header, _, _ := detectHeaderUnits(content, cs, countCommentUnits(r.Content, cs))
+			re.Rendered = r.Content
+			re.Matches = len(header) > 0 && e.headerMatches(r, header, cs)
+		}
+		exp.Rules = append(exp.Rules, re)
+	}
+	exp.Result = e.processFile(ctx, path, false)
+	return exp, nil
+}
+
+// ruleKind returns the kind of a rule.
+func ruleKind(tr TemplateRule) string {
+	switch {
+	case tr.Forbidden:
+		return RuleForbidden
+	case tr.deprecated:
+		return RuleDeprecated
+	}
+	return RuleRequired
+}
+
+// ruleApplies reports whether the include and exclude patterns of the rule
+// accept the relative path, and why.
+func ruleApplies(tr TemplateRule, rel string) (bool, string) {
+	if tr.Exclude != nil && tr.Exclude.MatchString(rel) {
+		return false, fmt.Sprintf("excluded by %s", tr.Exclude)
+	}
+	if tr.Include != nil && !tr.Include.MatchString(rel) {
+		return false, "not included by " + describePattern(tr.Include.String())
+	}
+	if tr.Include != nil {
+		return true, "included by " + describePattern(tr.Include.String())
+	}
+	return true, "no include pattern"
+}
+
+// describePattern names the default include pattern, which is too long to be
+// printed.
+func describePattern(pattern string) string {
+	if pattern == DefaultIncludeRegex {
+		return "the default include pattern"
+	}
+	return pattern
+}
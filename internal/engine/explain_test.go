@@ -0,0 +1,47 @@
+package engine
+
+import (
+	"context"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "tmpl.txt")
+	mustWrite(t, tmpl, []byte("Copyright Acme\n"))
+	old := filepath.Join(dir, "old.txt")
+	mustWrite(t, old, []byte("Proprietary\n"))
+	scripts := filepath.Join(dir, "scripts.txt")
+	mustWrite(t, scripts, []byte("Scripts\n"))
+	src := filepath.Join(dir, "main.go")
+	mustWrite(t, src, []byte("// Copyright Acme\n\npackage main\n"))
+
+	inc := regexp.MustCompile(DefaultIncludeRegex)
+	rules := []TemplateRule{
+		{TemplatePath: tmpl, Include: inc},
+		{TemplatePath: old, Include: inc, Exclude: regexp.MustCompile(`\.go$`), Forbidden: true},
+		{TemplatePath: scripts, Include: regexp.MustCompile(`\.sh$`)},
+	}
+	e, _ := New(Options{Root: dir, Rules: rules, Git: &fakeGit{touched: true}})
+	exp, err := e.Explain(context.Background(), src)
+	if err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	if exp.CommentStyle != "slash" || len(exp.Rules) != 3 {
+		t.Fatalf("unexpected explanation: %+v", exp)
+	}
+	if r := exp.Rules[0]; !r.Applies || !r.Matches || r.Kind != RuleRequired || r.Reason != "included by the default include pattern" || string(r.Rendered) != "// Copyright Acme\n" {
+		t.Fatalf("unexpected required rule: %+v", r)
+	}
+	if r := exp.Rules[1]; r.Applies || r.Kind != RuleForbidden || r.Reason != `excluded by \.go$` || r.Rendered != nil {
+		t.Fatalf("unexpected forbidden rule: %+v", r)
+	}
+	if r := exp.Rules[2]; r.Applies || r.Reason != `not included by \.sh$` {
+		t.Fatalf("unexpected scripts rule: %+v", r)
+	}
+	if exp.Result.Action != ActionNone || exp.Result.Template != tmpl {
+		t.Fatalf("unexpected result: %+v", exp.Result)
+	}
+}
@@ -0,0 +1,138 @@
+package engine
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FS is the file system the engine reads files and templates from, and writes
+// fixes to. Names are OS paths, as given to Process and in
+// TemplateRule.TemplatePath.
+type FS interface {
+	Stat(name string) (fs.FileInfo, error)
+	// Lstat is like Stat, without following a final symbolic link.
+	Lstat(name string) (fs.FileInfo, error)
+	// ReadDir returns the entries of a directory sorted by name.
+	ReadDir(name string) ([]fs.DirEntry, error)
+	ReadFile(name string) ([]byte, error)
+	// WriteFile replaces the content of an existing file.
+	WriteFile(name string, content []byte) error
+}
+
+// OSFS is the FS of the operating system, the default one. Its WriteFile
+// writes atomically and keeps the permissions of files, following symbolic
+// links.
+type OSFS struct{}
+
+func (OSFS) Stat(name string) (fs.FileInfo, error)      { return os.Stat(name) }
+func (OSFS) Lstat(name string) (fs.FileInfo, error)     { return os.Lstat(name) }
+func (OSFS) ReadDir(name string) ([]fs.DirEntry, error) { return os.ReadDir(name) }
+func (OSFS) ReadFile(name string) ([]byte, error)       { return os.ReadFile(name) }
+
+func (OSFS) WriteFile(name string, content []byte) error {
+	target, err := filepath.EvalSymlinks(name)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(target, content)
+}
+
+// ErrReadOnly is returned when writing to a read-only FS.
+var ErrReadOnly = errors.New("read-only file system")
+
+// FromFS returns a read-only FS serving the files of fsys, such as an archive,
+// an embedded tree or a fstest.MapFS, as if they were located under the OS
+// directory root.
+func FromFS(fsys fs.FS, root string) FS {
+	return ioFS{fsys: fsys, root: filepath.Clean(root)}
+}
+
+type ioFS struct {
+	fsys fs.FS
+	root string
+}
+
+// name maps an OS path under root to a name of fsys.
+func (f ioFS) name(op, name string) (string, error) {
+	rel, err := filepath.Rel(f.root, name)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+func (f ioFS) Stat(name string) (fs.FileInfo, error) {
+	n, err := f.name("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.Stat(f.fsys, n)
+}
+
+// Lstat is Stat: io/fs has no symbolic links.
+func (f ioFS) Lstat(name string) (fs.FileInfo, error) { return f.Stat(name) }
+
+func (f ioFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	n, err := f.name("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.ReadDir(f.fsys, n)
+}
+
+func (f ioFS) ReadFile(name string) ([]byte, error) {
+	n, err := f.name("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.ReadFile(f.fsys, n)
+}
+
+func (f ioFS) WriteFile(name string, _ []byte) error {
+	return &fs.PathError{Op: "write", Path: name, Err: ErrReadOnly}
+}
+
+// walkDir walks the file tree rooted at root like filepath.WalkDir, over fsys.
+func walkDir(fsys FS, root string, fn fs.WalkDirFunc) error {
+	info, err := fsys.Stat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walkDirEntry(fsys, root, fs.FileInfoToDirEntry(info), fn)
+	}
+	if errors.Is(err, filepath.SkipDir) {
+		return nil
+	}
+	return err
+}
+
+func walkDirEntry(fsys FS, path string, d fs.DirEntry, fn fs.WalkDirFunc) error {
+	if err := fn(path, d, nil); err != nil || !d.IsDir() {
+		if errors.Is(err, filepath.SkipDir) && d.IsDir() {
+			err = nil
+		}
+		return err
+	}
+	entries, err := fsys.ReadDir(path)
+	if err != nil {
+		// report the error a second time, as filepath.WalkDir does
+		if err = fn(path, d, err); err != nil {
+			if errors.Is(err, filepath.SkipDir) {
+				err = nil
+			}
+			return err
+		}
+	}
+	for _, entry := range entries {
+		if err := walkDirEntry(fsys, filepath.Join(path, entry.Name()), entry, fn); err != nil {
+			if errors.Is(err, filepath.SkipDir) {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
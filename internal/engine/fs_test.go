@@ -0,0 +1,65 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestProcess_OverFS(t *testing.T) {
+	root := filepath.FromSlash("/virtual/repo")
+	fsys := FromFS(fstest.MapFS{
+		"tmpl.txt":           {Data: []byte("Copyright Acme\n")},
+		"a.go":               {Data: []byte("// Copyright Acme\n\npackage a\n")},
+		"b/b.go":             {Data: []byte("package b\n")},
+		"node_modules/m.js":  {Data: []byte("x()\n")},
+		"b/c/generated.go":   {Data: []byte("// Code generated by x. DO NOT EDIT.\n\npackage c\n")},
+		"b/c/deeper/d.go":    {Data: []byte("package d\n")},
+		"b/c/deeper/notes.x": {Data: []byte("notes\n")},
+	}, root)
+
+	rules := []TemplateRule{{TemplatePath: filepath.Join(root, "tmpl.txt"), Include: regexp.MustCompile(DefaultIncludeRegex)}}
+	e, err := New(Options{Root: root, Rules: rules, Git: &fakeGit{touched: true}, FS: fsys})
+	if err != nil {
+		t.Fatalf("init engine: %v", err)
+	}
+	res, err := e.Process(context.Background(), []string{root}, false)
+	if err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	var got []string
+	for _, r := range res {
+		rel, _ := filepath.Rel(root, r.Path)
+		got = append(got, filepath.ToSlash(rel)+":"+string(r.Action))
+	}
+	want := "a.go:none,b/b.go:insert,b/c/deeper/d.go:insert,b/c/deeper/notes.x:none,b/c/generated.go:none,tmpl.txt:none"
+	if strings.Join(got, ",") != want {
+		t.Fatalf("got %s, want %s", strings.Join(got, ","), want)
+	}
+
+	res, err = e.Process(context.Background(), []string{filepath.Join(root, "b", "b.go")}, true)
+	if err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	if !errors.Is(res[0].Err, ErrReadOnly) {
+		t.Fatalf("fixing a read-only FS must fail: %+v", res[0])
+	}
+
+	e, _ = New(Options{Root: root, Rules: rules, Git: &fakeGit{touched: true}, FS: fsys, DryRun: true})
+	res, _ = e.Process(context.Background(), []string{filepath.Join(root, "b", "b.go")}, true)
+	if res[0].Err != nil || string(res[0].NewContent) != "// Copyright Acme\n\npackage b\n" {
+		t.Fatalf("dry runs must work on read-only FS: %+v", res[0])
+	}
+}
+
+func TestNew_ReadsTemplatesFromFS(t *testing.T) {
+	fsys := FromFS(fstest.MapFS{}, "/virtual")
+	rules := []TemplateRule{{TemplatePath: filepath.FromSlash("/virtual/missing.txt")}}
+	if _, err := New(Options{Root: "/virtual", Rules: rules, FS: fsys}); err == nil {
+		t.Fatalf("expected an error for a template missing from the FS")
+	}
+}
@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// generatedMarkerLines is the number of lines at the top of a file searched
+// for a generated-file marker. Generated files often start with a license
+// header, the marker comes after it.
+const generatedMarkerLines = 50
+
+// goGeneratedMarker is the Go convention, https://go.dev/s/generatedcode. It
+// is matched against whole lines.
+var goGeneratedMarker = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+// generatedMarkers match the banners of generated files, against the text of
+// comment lines without their delimiters: the @generated tag and the banners
+// of common generators.
+var generatedMarkers = []*regexp.Regexp{
+	regexp.MustCompile(`^@generated(\s|$)`),
+	regexp.MustCompile(`^Generated by the protocol buffer compiler\.\s+DO NOT EDIT!$`),
+	regexp.MustCompile(`^NOTE: This class is auto generated by (OpenAPI Generator \(https://openapi-generator\.tech\)|the swagger code generator program)\.$`),
+	regexp.MustCompile(`(?i)^(this file (is|was) )?(auto[- ]?)?generated( file| code| by [^,.]+)?[,.;:!]?\s+do not (edit|modify)( this file| manually)?[.!]?$`),
+}
+
+// lineCommentPrefixes and blockComments start the comment lines searched for
+// generated-file markers.
+var (
+	lineCommentPrefixes = []string{"//", "#", "--", ";", "%", "'"}
+	blockComments       = [][2]string{{"/*", "*/"}, {"<!--", "-->"}, {"{-", "-}"}, {"(*", "*)"}}
+)
+
+// isGenerated reports whether content is a generated file, which must not get
+// a header: it would be lost on regeneration. Only the comment lines at the
+// top of the file are searched, up to the first line of code, so that markers
+// in strings or code do not count.
+func isGenerated(content []byte) bool {
+	// blockEnd is the end delimiter of the block comment being read, if any
+	var blockEnd string
+	for i := 0; i < generatedMarkerLines && len(content) > 0; i++ {
+		line := content
+		if nl := bytes.IndexByte(content, '\n'); nl >= 0 {
+			line, content = content[:nl], content[nl+1:]
+		} else {
+			content = nil
+		}
+		raw := string(bytes.TrimSuffix(line, []byte("\r")))
+		if goGeneratedMarker.MatchString(raw) {
+			return true
+		}
+		text := strings.TrimSpace(raw)
+		switch {
+		case text == "" || (i == 0 && strings.HasPrefix(text, "#!")):
+			continue
+		case blockEnd != "":
+			if idx := strings.Index(text, blockEnd); idx >= 0 {
+				text, blockEnd = text[:idx], ""
+			}
+			text = strings.TrimLeft(text, "*")
+		default:
+			var ok bool
+			if text, blockEnd, ok = commentText(text); !ok {
+				return false
+			}
+		}
+		text = strings.TrimSpace(text)
+		for _, rx := range generatedMarkers {
+			if rx.MatchString(text) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// commentText returns the text of a line starting a comment, without its
+// delimiters, and the end delimiter of the block comment it opens and does not
+// close. ok is false when the line is not a comment.
+func commentText(line string) (text, blockEnd string, ok bool) {
+	for _, b := range blockComments {
+		if !strings.HasPrefix(line, b[0]) {
+			continue
+		}
+		text = strings.TrimLeft(line[len(b[0]):], "*")
+		if idx := strings.Index(text, b[1]); idx >= 0 {
+			return text[:idx], "", true
+		}
+		return text, b[1], true
+	}
+	for _, p := range lineCommentPrefixes {
+		if strings.HasPrefix(line, p) {
+			return strings.TrimLeft(line, p[:1]+"!/ "), "", true
+		}
+	}
+	return "", "", false
+}
@@ -0,0 +1,72 @@
+package engine
+
+import (
+	"context"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestIsGenerated(t *testing.T) {
+	cases := []struct {
+		content string
+		want    bool
+	}{
+		{"// Code generated by protoc-gen-go. DO NOT EDIT.\n\npackage pb\n", true},
+		{"// Copyright Acme\n\n// Code generated by mockgen. DO NOT EDIT.\r\n\npackage mocks\n", true},
+		{"/**\n * @generated\n */\n", true},
+		{"# Generated by the protocol buffer compiler.  DO NOT EDIT!\n", true},
+		{"/*\n * NOTE: This class is auto generated by OpenAPI Generator (https://openapi-generator.tech).\n */\n", true},
+		{"# Generated file, do not edit.\n", true},
+		{"// Code generated by hand, please edit.\npackage main\n", false},
+		{"// Package gen generates code.\npackage gen\n", false},
+		{strings.Repeat("\n", generatedMarkerLines) + "// Code generated by x. DO NOT EDIT.\n", false},
+		{"#!/bin/sh\n# Generated file, do not edit.\n", true},
+		{"<!--\n  @generated\n-->\n", true},
+		// markers after the first line of code, or inside strings, do not count
+		{"package engine\n\n// Code generated by x. DO NOT EDIT.\n", false},
+		{"package engine\n\nvar marker = \"@generated\"\n", false},
+		{"var rx = `generated file, do not edit`\n", false},
+		{"// Package x handles files marked @generated, do not edit them by hand.\npackage x\n", false},
+	}
+	for _, c := range cases {
+		if got := isGenerated([]byte(c.content)); got != c.want {
+			t.Errorf("isGenerated(%q) = %v, want %v", c.content, got, c.want)
+		}
+	}
+}
+
+func TestProcess_GeneratedFileIsSkipped(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "tmpl.txt")
+	mustWrite(t, tmpl, []byte("Copyright Acme\n"))
+	src := filepath.Join(dir, "gen.go")
+	orig := []byte("// Code generated by stringer. DO NOT EDIT.\n\npackage main\n")
+	mustWrite(t, src, orig)
+
+	rules := []TemplateRule{{TemplatePath: tmpl, Include: regexp.MustCompile(DefaultIncludeRegex)}}
+	e, err := New(Options{Root: dir, Rules: rules, Git: &fakeGit{touched: true}})
+	if err != nil {
+		t.Fatalf("init engine: %v", err)
+	}
+	res, err := e.Process(context.Background(), []string{src}, true)
+	if err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	if res[0].Action != ActionNone || res[0].Skipped != "generated file" {
+		t.Fatalf("unexpected result: %+v", res[0])
+	}
+	if got := string(mustRead(t, src)); got != string(orig) {
+		t.Fatalf("generated file must not change, got:\n%s", got)
+	}
+
+	e, _ = New(Options{Root: dir, Rules: rules, Git: &fakeGit{touched: true}, IncludeGenerated: true})
+	res, err = e.Process(context.Background(), []string{src}, false)
+	if err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	if res[0].Action == ActionNone {
+		t.Fatalf("generated file must be checked with IncludeGenerated, got: %+v", res[0])
+	}
+}
@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+)
+
+// ignoreMarkerLines is the number of lines at the top of a file searched for
+// an ignore marker.
+const ignoreMarkerLines = 10
+
+// ignoreMarkers make headercheck leave a file alone when written in a comment
+// near its top, optionally followed by a reason:
+//
+//	// headercheck:ignore vendored from upstream
+var ignoreMarkers = []string{"headercheck:ignore", "headercheck:disable"}
+
+// commentClosers are trimmed from the end of an ignore reason.
+var commentClosers = []string{"*/", "-->", "-}", "*)", "]]", `"""`}
+
+// findIgnoreMarker looks for an ignore marker in the first lines of content
+// and returns the marker and the reason following it.
+func findIgnoreMarker(content []byte) (marker, reason string, ok bool) {
+	for i := 0; i < ignoreMarkerLines && len(content) > 0; i++ {
+		line := content
+		if nl := bytes.IndexByte(content, '\n'); nl >= 0 {
+			line, content = content[:nl], content[nl+1:]
+		} else {
+			content = nil
+		}
+		for _, m := range ignoreMarkers {
+			idx := bytes.Index(line, []byte(m))
+			if idx < 0 || !isCommentPrefix(string(line[:idx])) {
+				continue
+			}
+			return m, ignoreReason(string(line[idx+len(m):])), true
+		}
+	}
+	return "", "", false
+}
+
+// isCommentPrefix reports whether the text before a marker is made of comment
+// markers only, so that markers in strings or code are not honoured.
+func isCommentPrefix(prefix string) bool {
+	switch prefix = strings.TrimSpace(prefix); {
+	case prefix == "":
+		return false
+	case strings.EqualFold(prefix, "rem"):
+		return true
+	}
+	for _, r := range prefix {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '"' || r == '\'' || r == '`' {
+			return false
+		}
+	}
+	return true
+}
+
+// ignoreReason cleans the text following an ignore marker.
+func ignoreReason(rest string) string {
+	rest = strings.TrimSpace(rest)
+	for _, c := range commentClosers {
+		rest = strings.TrimSpace(strings.TrimSuffix(rest, c))
+	}
+	return strings.TrimSpace(strings.TrimLeft(rest, ":-= "))
+}
@@ -0,0 +1,58 @@
+package engine
+
+import (
+	"context"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestFindIgnoreMarker(t *testing.T) {
+	cases := []struct {
+		content string
+		marker  string
+		reason  string
+		ok      bool
+	}{
+		{"// headercheck:ignore\npackage main\n", "headercheck:ignore", "", true},
+		{"package main\n\n// headercheck:ignore vendored from upstream\n", "headercheck:ignore", "vendored from upstream", true},
+		{"#!/bin/sh\n# headercheck:disable: generated\n", "headercheck:disable", "generated", true},
+		{"/* headercheck:ignore legacy */\n", "headercheck:ignore", "legacy", true},
+		{"<!-- headercheck:ignore -- third party -->\n", "headercheck:ignore", "third party", true},
+		{"REM headercheck:ignore batch\n", "headercheck:ignore", "batch", true},
+		{"package main\n\nconst s = \"// headercheck:ignore\"\n", "", "", false},
+		{"headercheck:ignore\n", "", "", false},
+		{"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n// headercheck:ignore\n", "", "", false},
+	}
+	for _, c := range cases {
+		marker, reason, ok := findIgnoreMarker([]byte(c.content))
+		if marker != c.marker || reason != c.reason || ok != c.ok {
+			t.Errorf("findIgnoreMarker(%q) = %q, %q, %v; want %q, %q, %v", c.content, marker, reason, ok, c.marker, c.reason, c.ok)
+		}
+	}
+}
+
+func TestProcess_IgnoredFileIsSkipped(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "tmpl.txt")
+	mustWrite(t, tmpl, []byte("Copyright Acme\n"))
+	src := filepath.Join(dir, "main.go")
+	orig := []byte("// headercheck:ignore vendored\n\npackage main\n")
+	mustWrite(t, src, orig)
+
+	rules := []TemplateRule{{TemplatePath: tmpl, Include: regexp.MustCompile(DefaultIncludeRegex)}}
+	e, err := New(Options{Root: dir, Rules: rules, Git: &fakeGit{touched: true}})
+	if err != nil {
+		t.Fatalf("init engine: %v", err)
+	}
+	res, err := e.Process(context.Background(), []string{src}, true)
+	if err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	if len(res) != 1 || res[0].Action != ActionNone || res[0].Skipped != "headercheck:ignore: vendored" {
+		t.Fatalf("unexpected results: %+v", res)
+	}
+	if got := string(mustRead(t, src)); got != string(orig) {
+		t.Fatalf("ignored file must not change, got:\n%s", got)
+	}
+}
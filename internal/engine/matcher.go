@@ -0,0 +1,750 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+// headerMatcher matches the existing headers of a file against a template:
+// literal text must be equal (modulo comment markers and whitespace) while the
+// variable slots of the template are checked against the data of the file.
+type headerMatcher struct {
+	*compiledMatcher
+	data TemplateData
+}
+
+// compiledMatcher is the part of a matcher shared by the files whose literal
+// actions render alike and which know the same variables.
+type compiledMatcher struct {
+	re    *regexp.Regexp
+	lines []*matcherLine
+	// slots are all the slots of the template, one capturing group each.
+	slots []matchSlot
+	// multiline is set when a slot may span several lines, in which case the
+	// header cannot be diagnosed line by line.
+	multiline bool
+}
+
+// matchSlot is a variable part of a template.
+type matchSlot struct {
+	// name is the slot as written in the template, eg "%author%" or "{{ year .Now }}".
+	name string
+	// pattern is the format of the value, re matches a whole value.
+	pattern string
+	re      *regexp.Regexp
+	// field is the variable rendered by the slot, named as the legacy
+	// variables without percent signs (eg "creation_date"), when its value can
+	// be carried over to another template.
+	field string
+	// deps are the TemplateData fields the value is computed from.
+	deps []string
+	// render computes the value of a Go template action. Legacy variables are
+	// the value of their single dependency.
+	render *template.Template
+}
+
+// known reports whether the data has a value for every field the slot depends
+// on. Slots with an unknown value may be left empty.
+func (s matchSlot) known(data TemplateData) bool {
+	for _, d := range s.deps {
+		if fieldValue(data, d) == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// stable reports whether the value of the slot only depends on fields that do
+// not change once the file is committed, so that it must be rendered exactly.
+func (s matchSlot) stable() bool {
+	for _, d := range s.deps {
+		if d != "Author" && d != "CreationDate" && d != "CreationYear" {
+			return false
+		}
+	}
+	return len(s.deps) > 0
+}
+
+// value renders the value of the slot for the data of a file.
+func (s matchSlot) value(data TemplateData) (string, error) {
+	if s.render == nil {
+		return fieldValue(data, s.deps[0]), nil
+	}
+	return executeTemplate(s.render, data)
+}
+
+// fieldValue returns the value of a git or time field of data, "" when unknown.
+func fieldValue(data TemplateData, name string) string {
+	switch name {
+	case "Author":
+		return data.Author
+	case "CreationDate":
+		return data.CreationDate
+	case "LastUpdateDate":
+		return data.LastUpdateDate
+	case "CreationYear":
+		return data.CreationYear
+	case "LastUpdateYear":
+		return data.LastUpdateYear
+	case "YearRange":
+		return data.YearRange
+	case "Now":
+		if !data.Now.IsZero() {
+			return data.Now.Format(time.RFC3339)
+		}
+	}
+	return ""
+}
+
+// valid reports whether a value is acceptable for a slot: it must have the
+// format of the slot and, when the file has git metadata, agree with it. The
+// last update of a header may lag behind the history of the file, so it only
+// has to fall between the creation of the file and today.
+func (m *headerMatcher) valid(s matchSlot, value string) bool {
+	known := s.known(m.data)
+	if value == "" && !known {
+		return true
+	}
+	if !s.re.MatchString(value) {
+		return false
+	}
+	if !known {
+		return true
+	}
+	switch s.field {
+	case "year_range":
+		return yearRangeValid(value, m.data.CreationYear)
+	case "last_update_date":
+		return between(value, m.data.CreationDate, formatNow(m.data, "2006-01-02"))
+	case "last_update_year":
+		return between(value, m.data.CreationYear, formatNow(m.data, "2006"))
+	}
+	if !s.stable() {
+		return true
+	}
+	want, err := s.value(m.data)
+	return err == nil && value == whitespaceRun.ReplaceAllString(strings.TrimSpace(want), " ")
+}
+
+// formatNow formats the current time of data, "" when unknown.
+func formatNow(data TemplateData, layout string) string {
+	if data.Now.IsZero() {
+		return ""
+	}
+	return data.Now.Format(layout)
+}
+
+// between reports whether first <= v <= last, for dates or years. Empty
+// bounds are ignored.
+func between(v, first, last string) bool {
+	return (first == "" || v >= first) && (last == "" || v <= last)
+}
+
+// matcherLine is one line of a template, used for diagnostics.
+type matcherLine struct {
+	pattern  strings.Builder
+	skeleton strings.Builder
+	display  strings.Builder
+	slots    []matchSlot
+
+	re         *regexp.Regexp
+	skeletonRe *regexp.Regexp
+}
+
+func (l *matcherLine) empty() bool { return l.display.Len() == 0 }
+
+// Validation patterns of the template variables. Values computed from git
+// history may only be empty when git metadata is not available, see
+// matcherBuilder.slot.
+const (
+	datePattern   = `\d{4}-\d{2}-\d{2}`
+	yearPattern   = `\d{4}`
+	authorPattern = `[^<>\n]+(?: <[^<>\s]+>)?`
+	rangePattern  = `\d{4}(?:-\d{4})?`
+	anyPattern    = `[^\n]*`
+)
+
+// legacyVariables are the %variables% of plain templates, with their pattern
+// and the TemplateData field they render.
+var legacyVariables = map[string]struct{ pattern, dep string }{
+	"%author%":           {authorPattern, "Author"},
+	"%creation_date%":    {datePattern, "CreationDate"},
+	"%last_update_date%": {datePattern, "LastUpdateDate"},
+	"%creation_year%":    {yearPattern, "CreationYear"},
+	"%last_update_year%": {yearPattern, "LastUpdateYear"},
+	"%year_range%":       {rangePattern, "YearRange"},
+}
+
+var legacyVariableRx = regexp.MustCompile(`%(author|creation_date|last_update_date|creation_year|last_update_year|year_range)%`)
+
+// yearRangeValid accepts a year range whose start is the creation year of the
+// file and whose end is not before it.
+func yearRangeValid(v, creationYear string) bool {
+	first, last := v, v
+	if i := strings.IndexByte(v, '-'); i >= 0 {
+		first, last = v[:i], v[i+1:]
+	}
+	return first == creationYear && last >= creationYear
+}
+
+// matcherBuilder assembles a matcher from literal text and slots.
+type matcherBuilder struct {
+	lines        []*matcherLine
+	pendingSpace bool
+	afterSlot    bool
+	multiline    bool
+}
+
+func (b *matcherBuilder) line() *matcherLine {
+	if len(b.lines) == 0 {
+		b.lines = append(b.lines, &matcherLine{})
+	}
+	return b.lines[len(b.lines)-1]
+}
+
+// space flushes a pending space before content. Spaces next to a slot are
+// optional, so that an empty value matches a trimmed line.
+func (b *matcherBuilder) space(beforeSlot bool) {
+	if !b.pendingSpace {
+		return
+	}
+	l := b.line()
+	if beforeSlot || b.afterSlot {
+		l.pattern.WriteString(` ?`)
+		l.skeleton.WriteString(` ?`)
+	} else {
+		l.pattern.WriteString(` `)
+		l.skeleton.WriteString(` `)
+	}
+	l.display.WriteString(" ")
+	b.pendingSpace = false
+}
+
+// literal adds template text, normalized like normalizeHeaderText does.
+func (b *matcherBuilder) literal(text string) {
+	for _, r := range text {
+		switch r {
+		case '\n':
+			b.line()
+			b.lines = append(b.lines, &matcherLine{})
+			b.pendingSpace = false
+			b.afterSlot = false
+		case ' ', '\t', '\r':
+			if !b.line().empty() {
+				b.pendingSpace = true
+			}
+		default:
+			b.space(false)
+			l := b.line()
+			q := regexp.QuoteMeta(string(r))
+			l.pattern.WriteString(q)
+			l.skeleton.WriteString(q)
+			l.display.WriteRune(r)
+			b.afterSlot = false
+		}
+	}
+}
+
+// slot adds a variable part. The value of an optional slot may be empty.
+func (b *matcherBuilder) slot(s matchSlot, optional bool) {
+	b.space(true)
+	l := b.line()
+	if optional {
+		l.pattern.WriteString(`((?:` + s.pattern + `)?)`)
+	} else {
+		l.pattern.WriteString(`(` + s.pattern + `)`)
+	}
+	l.skeleton.WriteString(`(.*?)`)
+	l.display.WriteString(s.name)
+	l.slots = append(l.slots, s)
+	b.afterSlot = true
+}
+
+func (b *matcherBuilder) compile(name string) (*compiledMatcher, error) {
+	lines := b.lines
+	for len(lines) > 0 && lines[0].empty() {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1].empty() {
+		lines = lines[:len(lines)-1]
+	}
+	patterns := make([]string, 0, len(lines))
+	for _, l := range lines {
+		patterns = append(patterns, l.pattern.String())
+		var err error
+		if l.re, err = regexp.Compile(`^` + l.pattern.String() + `$`); err != nil {
+			return nil, fmt.Errorf("compile matcher for %s: %w", name, err)
+		}
+		if l.skeletonRe, err = regexp.Compile(`^` + l.skeleton.String() + `$`); err != nil {
+			return nil, fmt.Errorf("compile matcher for %s: %w", name, err)
+		}
+	}
+	re, err := regexp.Compile(`^` + strings.Join(patterns, `\n`) + `$`)
+	if err != nil {
+		return nil, fmt.Errorf("compile matcher for %s: %w", name, err)
+	}
+	var slots []matchSlot
+	for _, l := range lines {
+		slots = append(slots, l.slots...)
+	}
+	return &compiledMatcher{re: re, lines: lines, slots: slots, multiline: b.multiline}, nil
+}
+
+// matcherPart is a piece of the source of a template: literal text, a slot,
+// or an action that only depends on the file and configuration, rendered for
+// each file and compared as literal text.
+type matcherPart struct {
+	text   string
+	action *template.Template
+	slot   *matchSlot
+	// multiline is set on slots that may span several lines.
+	multiline bool
+}
+
+// maxCachedMatchers bounds the compiled matchers kept per rule: a template
+// rendering the path of the file as literal text compiles one per file.
+const maxCachedMatchers = 256
+
+// matcherCache holds the matchers of a loaded rule, shared by its rendered
+// copies: the parts of its template by comment style, and the compiled
+// matchers by the rendering of their literal actions and known slots.
+type matcherCache struct {
+	mu       sync.Mutex
+	parts    map[CommentStyle][]matcherPart
+	compiled map[string]*compiledMatcher
+}
+
+// compileMatcher derives the matcher of a template for a file. Pre-commented
+// templates have their comment markers stripped first, the same way existing
+// headers are before being matched. Matchers are cached on loaded rules.
+func compileMatcher(tr TemplateRule, data TemplateData, cs *CommentStyle) (*headerMatcher, error) {
+	c := tr.matchers
+	if c == nil {
+		c = &matcherCache{}
+	}
+	var style CommentStyle
+	if cs != nil {
+		style = *cs
+	}
+	c.mu.Lock()
+	parts, ok := c.parts[style]
+	c.mu.Unlock()
+	if !ok {
+		var err error
+		if parts, err = matcherParts(tr, cs); err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		if c.parts == nil {
+			c.parts = map[CommentStyle][]matcherPart{}
+		}
+		c.parts[style] = parts
+		c.mu.Unlock()
+	}
+
+	texts := make([]string, len(parts))
+	known := make([]bool, len(parts))
+	key := fmt.Sprintf("%+v", style)
+	for i, p := range parts {
+		switch {
+		case p.action != nil:
+			out, err := executeTemplate(p.action, data)
+			if err != nil {
+				return nil, fmt.Errorf("render template %s: %w", tr.TemplatePath, err)
+			}
+			texts[i] = out
+			key += "\x00" + out
+		case p.slot != nil:
+			known[i] = p.slot.known(data)
+			key += fmt.Sprintf("\x00%t", known[i])
+		default:
+			texts[i] = p.text
+		}
+	}
+
+	c.mu.Lock()
+	m, ok := c.compiled[key]
+	c.mu.Unlock()
+	if ok {
+		return &headerMatcher{compiledMatcher: m, data: data}, nil
+	}
+	b := &matcherBuilder{}
+	for i, p := range parts {
+		if p.slot == nil {
+			b.literal(texts[i])
+			continue
+		}
+		b.slot(*p.slot, !known[i])
+		b.multiline = b.multiline || p.multiline
+	}
+	m, err := b.compile(tr.TemplatePath)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if c.compiled == nil || len(c.compiled) >= maxCachedMatchers {
+		c.compiled = map[string]*compiledMatcher{}
+	}
+	c.compiled[key] = m
+	c.mu.Unlock()
+	return &headerMatcher{compiledMatcher: m, data: data}, nil
+}
+
+// matcherParts splits the source of a template into parts.
+func matcherParts(tr TemplateRule, cs *CommentStyle) ([]matcherPart, error) {
+	src := tr.Content
+	if !tr.neutral {
+		src = stripCommentMarkers(src, cs)
+	}
+	var parts []matcherPart
+	if tr.tmpl == nil {
+		text := string(src)
+		last := 0
+		for _, loc := range legacyVariableRx.FindAllStringIndex(text, -1) {
+			name := text[loc[0]:loc[1]]
+			v := legacyVariables[name]
+			s, err := newSlot(name, v.pattern, []string{v.dep})
+			if err != nil {
+				return nil, err
+			}
+			s.field = strings.Trim(name, "%")
+			parts = append(parts, matcherPart{text: text[last:loc[0]]}, matcherPart{slot: s})
+			last = loc[1]
+		}
+		return append(parts, matcherPart{text: text[last:]}), nil
+	}
+
+	t, err := parseGoTemplate(tr.TemplatePath, src)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range t.Tree.Root.Nodes {
+		if n, ok := n.(*parse.TextNode); ok {
+			parts = append(parts, matcherPart{text: string(n.Text)})
+			continue
+		}
+		action, err := parseGoTemplate("node", []byte(n.String()))
+		if err != nil {
+			return nil, err
+		}
+		// Actions that only depend on the file and configuration are
+		// rendered and compared as literal text.
+		if !gitOrTimeFieldRx.MatchString(n.String()) {
+			parts = append(parts, matcherPart{action: action})
+			continue
+		}
+		a, ok := n.(*parse.ActionNode)
+		if !ok {
+			// if/range/with blocks may expand to anything, including new lines
+			s, err := newSlot(n.String(), `(?s:.*?)`, nil)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, matcherPart{slot: s, multiline: true})
+			continue
+		}
+		var deps []string
+		for _, m := range gitOrTimeFieldRx.FindAllStringSubmatch(a.String(), -1) {
+			if !containsString(deps, m[1]) {
+				deps = append(deps, m[1])
+			}
+		}
+		s, err := newSlot(a.String(), actionPattern(a), deps)
+		if err != nil {
+			return nil, err
+		}
+		s.field = actionField(a, s.pattern)
+		s.render = action
+		parts = append(parts, matcherPart{slot: s})
+	}
+	return parts, nil
+}
+
+// newSlot returns a slot validated by pattern.
+func newSlot(name, pattern string, deps []string) (*matchSlot, error) {
+	re, err := regexp.Compile(`^(?:` + pattern + `)$`)
+	if err != nil {
+		return nil, fmt.Errorf("compile pattern of %s: %w", name, err)
+	}
+	return &matchSlot{name: name, pattern: pattern, re: re, deps: deps}, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// gitOrTimeFieldRx detects template actions depending on git history or time.
+var gitOrTimeFieldRx = regexp.MustCompile(`\.(Author|CreationDate|LastUpdateDate|CreationYear|LastUpdateYear|YearRange|Now)\b`)
+
+// executeTemplate renders a Go template to a string.
+func executeTemplate(t *template.Template, data TemplateData) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// actionField returns the variable rendered as is by a template action, in
+// the naming of matchSlot.field, or "" when the action transforms it.
+func actionField(a *parse.ActionNode, pattern string) string {
+	m := gitOrTimeFieldRx.FindStringSubmatch(a.String())
+	if m == nil || len(a.Pipe.Cmds) != 1 {
+		return ""
+	}
+	switch {
+	case m[1] == "Author" && pattern == authorPattern:
+		return "author"
+	case m[1] == "CreationDate" && pattern == datePattern:
+		return "creation_date"
+	case m[1] == "LastUpdateDate" && pattern == datePattern:
+		return "last_update_date"
+	case (m[1] == "CreationDate" || m[1] == "CreationYear") && pattern == yearPattern:
+		return "creation_year"
+	case (m[1] == "LastUpdateDate" || m[1] == "LastUpdateYear") && pattern == yearPattern:
+		return "last_update_year"
+	case m[1] == "YearRange" && pattern == rangePattern:
+		return "year_range"
+	}
+	return ""
+}
+
+// actionPattern returns the validation pattern of a template action, based on
+// the helper functions and fields it uses.
+func actionPattern(a *parse.ActionNode) string {
+	for _, cmd := range a.Pipe.Cmds {
+		if len(cmd.Args) == 0 {
+			continue
+		}
+		id, ok := cmd.Args[0].(*parse.IdentifierNode)
+		if !ok {
+			continue
+		}
+		switch id.Ident {
+		case "year":
+			return yearPattern
+		case "date":
+			if len(cmd.Args) > 1 {
+				if layout, ok := cmd.Args[1].(*parse.StringNode); ok {
+					return layoutPattern(layout.Text)
+				}
+			}
+			return anyPattern
+		case "default":
+			// the fallback makes the value unpredictable
+			return anyPattern
+		}
+	}
+	s := a.String()
+	switch {
+	case strings.Contains(s, ".YearRange"):
+		return rangePattern
+	case strings.Contains(s, ".CreationYear"), strings.Contains(s, ".LastUpdateYear"):
+		return yearPattern
+	case strings.Contains(s, ".CreationDate"), strings.Contains(s, ".LastUpdateDate"):
+		return datePattern
+	case strings.Contains(s, ".Author"):
+		return authorPattern
+	}
+	return anyPattern
+}
+
+// layoutElements maps Go time layout elements to patterns, longest first.
+var layoutElements = []struct{ elem, pattern string }{
+	{"January", `[A-Z][a-z]+`},
+	{"Monday", `[A-Z][a-z]+`},
+	{"2006", `\d{4}`},
+	{"Jan", `[A-Z][a-z]{2}`},
+	{"Mon", `[A-Z][a-z]{2}`},
+	{"MST", `[A-Z]+`},
+	{"01", `\d{2}`},
+	{"02", `\d{2}`},
+	{"15", `\d{2}`},
+	{"03", `\d{2}`},
+	{"04", `\d{2}`},
+	{"05", `\d{2}`},
+	{"06", `\d{2}`},
+	{"PM", `[AP]M`},
+	{"_2", `[ \d]\d`},
+	{"1", `\d{1,2}`},
+	{"2", `\d{1,2}`},
+	{"3", `\d{1,2}`},
+	{"4", `\d{1,2}`},
+	{"5", `\d{1,2}`},
+}
+
+// layoutPattern converts a Go time layout to a pattern.
+func layoutPattern(layout string) string {
+	var sb strings.Builder
+outer:
+	for len(layout) > 0 {
+		for _, el := range layoutElements {
+			if strings.HasPrefix(layout, el.elem) {
+				sb.WriteString(el.pattern)
+				layout = layout[len(el.elem):]
+				continue outer
+			}
+		}
+		sb.WriteString(regexp.QuoteMeta(layout[:1]))
+		layout = layout[1:]
+	}
+	return sb.String()
+}
+
+// normalizeHeaderText prepares a header whose comment markers were stripped for
+// matching: lines are trimmed, inner whitespace is collapsed and leading and
+// trailing blank lines are dropped.
+func normalizeHeaderText(b []byte) string {
+	lines := strings.Split(string(b), "\n")
+	for i, l := range lines {
+		lines[i] = whitespaceRun.ReplaceAllString(strings.TrimSpace(l), " ")
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+var whitespaceRun = regexp.MustCompile(`[ \t\r]+`)
+
+// match reports whether an existing header matches.
+func (m *headerMatcher) match(existing []byte, cs *CommentStyle) bool {
+	if len(existing) == 0 {
+		return false
+	}
+	return m.matchText(normalizeHeaderText(stripCommentMarkers(existing, cs)))
+}
+
+// matchText matches a normalized header and validates its slot values.
+func (m *headerMatcher) matchText(text string) bool {
+	sub := m.re.FindStringSubmatch(text)
+	if sub == nil {
+		return false
+	}
+	for i, s := range m.slots {
+		if !m.valid(s, sub[i+1]) {
+			return false
+		}
+	}
+	return true
+}
+
+// values returns the values captured by the slots of the matcher in an
+// existing header, by field. Empty values are left out.
+func (m *headerMatcher) values(existing []byte, cs *CommentStyle) map[string]string {
+	out := map[string]string{}
+	sub := m.re.FindStringSubmatch(normalizeHeaderText(stripCommentMarkers(existing, cs)))
+	if sub == nil {
+		return out
+	}
+	for i, s := range m.slots {
+		if _, ok := out[s.field]; s.field != "" && sub[i+1] != "" && !ok {
+			out[s.field] = sub[i+1]
+		}
+	}
+	return out
+}
+
+// explain describes why an existing header does not match, or returns an
+// empty string when it does.
+func (m *headerMatcher) explain(existing []byte, cs *CommentStyle) string {
+	text := normalizeHeaderText(stripCommentMarkers(existing, cs))
+	if m.matchText(text) {
+		return ""
+	}
+	if m.multiline {
+		return "header does not match the template"
+	}
+	got := strings.Split(text, "\n")
+	for i, l := range m.lines {
+		if i >= len(got) {
+			return fmt.Sprintf("header line %d: missing, expected %q", i+1, l.display.String())
+		}
+		sub := l.re.FindStringSubmatch(got[i])
+		if sub == nil {
+			sub = l.skeletonRe.FindStringSubmatch(got[i])
+		}
+		if sub != nil {
+			for j, s := range l.slots {
+				if !m.valid(s, sub[j+1]) {
+					return fmt.Sprintf("header line %d: invalid value %q for %s", i+1, sub[j+1], s.name)
+				}
+			}
+			if l.re.MatchString(got[i]) {
+				continue
+			}
+		}
+		return fmt.Sprintf("header line %d: expected %q, got %q", i+1, l.display.String(), got[i])
+	}
+	if len(got) > len(m.lines) {
+		return fmt.Sprintf("header line %d: unexpected %q", len(m.lines)+1, got[len(m.lines)])
+	}
+	return "header does not match the template"
+}
+
+// sameLayout reports whether an existing header has the lines of the template
+// with any values in its slots. Headers with multiline slots never do.
+func (m *headerMatcher) sameLayout(existing []byte, cs *CommentStyle) bool {
+	if m.multiline || len(existing) == 0 {
+		return false
+	}
+	got := strings.Split(normalizeHeaderText(stripCommentMarkers(existing, cs)), "\n")
+	if len(got) != len(m.lines) {
+		return false
+	}
+	for i, l := range m.lines {
+		if !l.skeletonRe.MatchString(got[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// matcherFor returns the matcher of a rendered rule for its file.
+func (e *Engine) matcherFor(tr TemplateRule, cs *CommentStyle) (*headerMatcher, error) {
+	var data TemplateData
+	if tr.data != nil {
+		data = *tr.data
+	}
+	// tr is a rendered rule: compile the template source
+	return compileMatcher(e.opts.Rules[tr.idx], data, cs)
+}
+
+// headerMatches reports whether the existing header matches a rendered rule.
+func (e *Engine) headerMatches(tr TemplateRule, existing []byte, cs *CommentStyle) bool {
+	m, err := e.matcherFor(tr, cs)
+	if err != nil {
+		return false
+	}
+	return m.match(existing, cs)
+}
+
+// sameLayout reports whether the existing header has the lines of a rendered
+// rule, whatever the values of its slots.
+func (e *Engine) sameLayout(tr TemplateRule, existing []byte, cs *CommentStyle) bool {
+	m, err := e.matcherFor(tr, cs)
+	if err != nil {
+		return false
+	}
+	return m.sameLayout(existing, cs)
+}
+
+// explainMismatch describes why the existing header does not match a rendered rule.
+func (e *Engine) explainMismatch(tr TemplateRule, existing []byte, cs *CommentStyle) string {
+	if len(bytes.TrimSpace(existing)) == 0 {
+		return ""
+	}
+	m, err := e.matcherFor(tr, cs)
+	if err != nil {
+		return err.Error()
+	}
+	return m.explain(existing, cs)
+}
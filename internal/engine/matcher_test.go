@@ -0,0 +1,199 @@
+package engine
+
+import (
+	"context"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustMatcher(t *testing.T, tmpl string, data TemplateData) *headerMatcher {
+	t.Helper()
+	tr := TemplateRule{TemplatePath: "tmpl", Content: []byte(tmpl), neutral: !isPreCommented([]byte(tmpl))}
+	if isGoTemplate(tr.Content) {
+		var err error
+		if tr.tmpl, err = parseGoTemplate("tmpl", tr.Content); err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+	}
+	m, err := compileMatcher(tr, data, nil)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	return m
+}
+
+func TestMatcher_LegacyVariablesAreValidated(t *testing.T) {
+	m := mustMatcher(t, "// Copyright 2024 Acme\n// Author: %author%\n// Created: %creation_date%\n", TemplateData{})
+	cases := []struct {
+		header string
+		want   bool
+	}{
+		{"// Copyright 2024 Acme\n// Author: Jane Doe <jane@doe.com>\n// Created: 2020-01-02\n", true},
+		{"// Copyright 2024 Acme\n// Author: unknown\n// Created:\n", true},
+		{"/*\n * Copyright 2024 Acme\n * Author: Jane Doe\n * Created: 2020-01-02\n */\n", true},
+		// the year is literal text
+		{"// Copyright 2023 Acme\n// Author: Jane Doe\n// Created: 2020-01-02\n", false},
+		// hex-looking words are not ignored
+		{"// Copyright 2024 deadbeef\n// Author: Jane Doe\n// Created: 2020-01-02\n", false},
+		{"// Copyright 2024 Acme\n// Author: Jane Doe\n// Created: yesterday\n", false},
+		{"// Copyright 2024 Acme\n// Author: Jane Doe\n", false},
+	}
+	for _, c := range cases {
+		if got := m.match([]byte(c.header), nil); got != c.want {
+			t.Fatalf("match(%q) = %v, want %v", c.header, got, c.want)
+		}
+	}
+}
+
+func TestMatcher_LiteralEmailMustMatch(t *testing.T) {
+	m := mustMatcher(t, "Maintainer: John <john@acme.com>\nAuthor: %author%\n", TemplateData{})
+	if m.match([]byte("# Maintainer: Eve <eve@evil.com>\n# Author: Jane\n"), nil) {
+		t.Fatalf("a different literal email must not match")
+	}
+	if !m.match([]byte("# Maintainer: John <john@acme.com>\n# Author: Jane <jane@doe.com>\n"), nil) {
+		t.Fatalf("expected match")
+	}
+}
+
+func TestMatcher_GoTemplateSlots(t *testing.T) {
+	data := TemplateData{Path: "a/b.go", Vars: map[string]string{"company": "Acme"}}
+	m := mustMatcher(t, "Copyright {{ year .Now }} {{ .Vars.company }}\nFile: {{ .Path }}\nUpdated: {{ date \"Jan 2006\" .LastUpdateDate }}\n", data)
+	if !m.match([]byte("// Copyright 2019 Acme\n// File: a/b.go\n// Updated: Feb 2024\n"), nil) {
+		t.Fatalf("expected match")
+	}
+	if m.match([]byte("// Copyright 2019 Acme\n// File: c/d.go\n// Updated: Feb 2024\n"), nil) {
+		t.Fatalf("file-dependent values are literal")
+	}
+	if m.match([]byte("// Copyright 19 Acme\n// File: a/b.go\n// Updated: Feb 2024\n"), nil) {
+		t.Fatalf("year slot must be validated")
+	}
+}
+
+func TestMatcher_Explain(t *testing.T) {
+	m := mustMatcher(t, "Copyright 2024 Acme\nCreated: %creation_date%\n", TemplateData{})
+	cases := []struct {
+		header string
+		want   string
+	}{
+		{"// Copyright 2023 Acme\n// Created: 2020-01-01\n", `header line 1: expected "Copyright 2024 Acme", got "Copyright 2023 Acme"`},
+		{"// Copyright 2024 Acme\n// Created: soon\n", `header line 2: invalid value "soon" for %creation_date%`},
+		{"// Copyright 2024 Acme\n", `header line 2: missing, expected "Created: %creation_date%"`},
+		{"// Copyright 2024 Acme\n// Created:\n// extra\n", `header line 3: unexpected "extra"`},
+		{"// Copyright 2024 Acme\n// Created: 2020-01-01\n", ""},
+	}
+	for _, c := range cases {
+		if got := m.explain([]byte(c.header), nil); got != c.want {
+			t.Fatalf("explain(%q) = %q, want %q", c.header, got, c.want)
+		}
+	}
+}
+
+func TestProcess_ReportsMismatchReason(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "tmpl.txt")
+	mustWrite(t, tmpl, []byte("// Copyright 2024 Acme\n// Author: %author%\n"))
+	src := filepath.Join(dir, "hello.go")
+	mustWrite(t, src, []byte("// Copyright 2021 Acme\n// Author: Jane <jane@doe.com>\n\npackage main\n"))
+	missing := filepath.Join(dir, "other.go")
+	mustWrite(t, missing, []byte("package main\n"))
+
+	rules := []TemplateRule{{TemplatePath: tmpl, Include: regexp.MustCompile(DefaultIncludeRegex)}}
+	e, _ := New(Options{Root: dir, Rules: rules, Git: &fakeGit{author: "Bob", touched: true}})
+	res, err := e.Process(context.Background(), []string{src, missing}, false)
+	if err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	if res[0].Action != ActionReplace || !strings.Contains(res[0].Reason, "header line 1") {
+		t.Fatalf("expected replace with reason, got: %+v", res[0])
+	}
+	if res[1].Action != ActionInsert || res[1].Reason == "" {
+		t.Fatalf("expected insert with reason, got: %+v", res[1])
+	}
+}
+
+func TestMatcher_SlotsAreCheckedAgainstGit(t *testing.T) {
+	data := TemplateData{
+		Author:         "Jane Doe <jane@doe.com>",
+		CreationDate:   "2019-03-04",
+		LastUpdateDate: "2021-01-01",
+		CreationYear:   "2019",
+		LastUpdateYear: "2021",
+		YearRange:      "2019-2021",
+		Now:            time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+	}
+	m := mustMatcher(t, "// Copyright %year_range% Acme\n// Author: %author%\n// Created: %creation_date%\n// Updated: %last_update_date%\n", data)
+	cases := []struct {
+		header string
+		want   bool
+	}{
+		{"// Copyright 2019-2021 Acme\n// Author: Jane Doe <jane@doe.com>\n// Created: 2019-03-04\n// Updated: 2021-01-01\n", true},
+		// the last update may lag behind the history
+		{"// Copyright 2019 Acme\n// Author: Jane Doe <jane@doe.com>\n// Created: 2019-03-04\n// Updated: 2020-01-01\n", true},
+		{"// Copyright 2019-2021 Acme\n// Author: Bob <bob@example.com>\n// Created: 2019-03-04\n// Updated: 2021-01-01\n", false},
+		{"// Copyright 2019-2021 Acme\n// Author: Jane Doe <jane@doe.com>\n// Created:\n// Updated: 2021-01-01\n", false},
+		{"// Copyright 2019-2021 Acme\n// Author: Jane Doe <jane@doe.com>\n// Created: 2018-03-04\n// Updated: 2021-01-01\n", false},
+		{"// Copyright 2019-2021 Acme\n// Author: Jane Doe <jane@doe.com>\n// Created: 2019-03-04\n// Updated: 2018-01-01\n", false},
+		{"// Copyright 2019-2021 Acme\n// Author: Jane Doe <jane@doe.com>\n// Created: 2019-03-04\n// Updated: 2025-01-01\n", false},
+		{"// Copyright 2018-2021 Acme\n// Author: Jane Doe <jane@doe.com>\n// Created: 2019-03-04\n// Updated: 2021-01-01\n", false},
+	}
+	for _, c := range cases {
+		if got := m.match([]byte(c.header), nil); got != c.want {
+			t.Fatalf("match(%q) = %v, want %v", c.header, got, c.want)
+		}
+	}
+
+	g := mustMatcher(t, "Copyright {{ year .CreationDate }} {{ .Author | upper }}\n", data)
+	if !g.match([]byte("// Copyright 2019 JANE DOE <JANE@DOE.COM>\n"), nil) {
+		t.Fatalf("expected match")
+	}
+	if g.match([]byte("// Copyright 2018 JANE DOE <JANE@DOE.COM>\n"), nil) {
+		t.Fatalf("the creation year must be the one of the file")
+	}
+}
+
+func TestCompileMatcher_IsCached(t *testing.T) {
+	tr := TemplateRule{TemplatePath: "tmpl", Content: []byte("Copyright Acme\nCreated: %creation_date%\n"), neutral: true, matchers: &matcherCache{}}
+	a, err := compileMatcher(tr, TemplateData{CreationDate: "2020-01-01"}, nil)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	b, _ := compileMatcher(tr, TemplateData{CreationDate: "2021-01-01"}, nil)
+	if a.compiledMatcher != b.compiledMatcher {
+		t.Fatalf("files knowing the same variables must share a matcher")
+	}
+	c, _ := compileMatcher(tr, TemplateData{}, nil)
+	if c.compiledMatcher == a.compiledMatcher || !c.match([]byte("// Copyright Acme\n// Created:\n"), nil) {
+		t.Fatalf("unknown variables may be left empty")
+	}
+	if a.match([]byte("// Copyright Acme\n// Created:\n"), nil) {
+		t.Fatalf("known variables must not be left empty")
+	}
+}
+
+func TestProcess_OutdatedHeaderIsReplacedInPlace(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "tmpl.txt")
+	mustWrite(t, tmpl, []byte("Copyright %year_range% Acme\n"))
+	src := filepath.Join(dir, "hello.go")
+	mustWrite(t, src, []byte("// Copyright 2018-2020 Acme\n\npackage main\n"))
+
+	rules := []TemplateRule{{TemplatePath: tmpl, Include: regexp.MustCompile(DefaultIncludeRegex)}}
+	e, _ := New(Options{Root: dir, Rules: rules, Git: &fakeGit{created: "2019-05-01", updated: "2020-02-01"}})
+	res, err := e.Process(context.Background(), []string{src}, false)
+	if err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	if res[0].Action != ActionReplace || !strings.Contains(res[0].Reason, `invalid value "2018-2020"`) {
+		t.Fatalf("expected replace with reason, got: %+v", res[0])
+	}
+	if _, err := e.Process(context.Background(), []string{src}, true); err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	// the old notice is not kept next to the new one
+	if got, want := string(mustRead(t, src)), "// Copyright 2019-2020 Acme\n\npackage main\n"; got != want {
+		t.Fatalf("GOT:\n%q\nWANT:\n%q", got, want)
+	}
+}
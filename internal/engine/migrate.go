@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+)
+
+// handleDeprecatedHeader replaces a header matching a deprecated template with
+// the template replacing it, in place: unlike unknown headers, the old header
+// is not preserved. Values the file has no git metadata for, such as the
+// author or the creation date, are carried over from the old header.
+func (e *Engine) handleDeprecatedHeader(path string, content []byte, trules, deprecated []TemplateRule, cs *CommentStyle) (FileResult, bool) {
+	for _, old := range deprecated {
+		existing, start, end := detectHeaderUnits(content, cs, countCommentUnits(old.Content, cs))
+		if len(existing) == 0 || old.data == nil {
+			continue
+		}
+		// Old headers may predate the history of the file: accept any value
+		data := *old.data
+		data.Author, data.CreationDate, data.LastUpdateDate = "", "", ""
+		data.CreationYear, data.LastUpdateYear, data.YearRange = "", "", ""
+		m, err := compileMatcher(e.opts.Rules[old.idx], data, cs)
+		if err != nil || !m.match(existing, cs) {
+			continue
+		}
+		var parent TemplateRule
+		for _, tr := range trules {
+			if tr.idx == old.replacedBy {
+				parent = tr
+			}
+		}
+		if parent.data == nil {
+			continue
+		}
+
+		res := FileResult{
+			Path:     path,
+			Action:   ActionReplace,
+			Reason:   fmt.Sprintf("header matches deprecated template %s", e.relativePath(old.TemplatePath)),
+			Template: parent.TemplatePath,
+			Header:   existing,
+			Line:     lineAt(content, start),
+			Offset:   start,
+		}
+		rendered, err := e.renderRule(e.opts.Rules[parent.idx], path, carryValues(*parent.data, m.values(existing, cs)))
+		if err != nil {
+			res.Err = err
+			return res, true
+		}
+		res.Expected = rendered.Content
+		res.NewContent = replaceHeader(content, start, end, rendered.Content)
+		return res, true
+	}
+	return FileResult{}, false
+}
+
+// carryValues fills the git-derived fields of data that are unknown with the
+// values found in an old header.
+func carryValues(data TemplateData, values map[string]string) TemplateData {
+	if data.Author == "" {
+		if v := values["author"]; v != "" {
+			data.Author = v
+		}
+	}
+	if data.CreationDate == "" {
+		data.CreationDate = values["creation_date"]
+	}
+	if data.LastUpdateDate == "" {
+		data.LastUpdateDate = values["last_update_date"]
+	}
+	first, last := values["year_range"], values["year_range"]
+	if i := strings.IndexByte(first, '-'); i >= 0 {
+		first, last = first[:i], first[i+1:]
+	}
+	if data.CreationYear == "" {
+		data.CreationYear = firstNonEmpty(formatDate("2006", data.CreationDate), values["creation_year"], first)
+	}
+	if data.LastUpdateYear == "" {
+		data.LastUpdateYear = firstNonEmpty(formatDate("2006", data.LastUpdateDate), values["last_update_year"], last)
+	}
+	data.YearRange = yearRange(data.CreationYear, data.LastUpdateYear)
+	return data
+}
+
+// firstNonEmpty returns the first non-empty value.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
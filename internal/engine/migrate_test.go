@@ -0,0 +1,58 @@
+package engine
+
+import (
+	"context"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestProcess_DeprecatedHeaderIsMigrated(t *testing.T) {
+	dir := t.TempDir()
+	mit := filepath.Join(dir, "mit.txt")
+	mustWrite(t, mit, []byte("Copyright %year_range% %author%\nLicensed under the MIT license.\n"))
+	apache := filepath.Join(dir, "apache.txt")
+	mustWrite(t, apache, []byte("Copyright {{ .YearRange }} {{ .Author }}\nCreated: {{ .CreationDate }}\nLicensed under the Apache License, Version 2.0.\n"))
+	src := filepath.Join(dir, "main.go")
+	mustWrite(t, src, []byte("// Copyright 2015-2019 Jane Doe <jane@doe.com>\n// Licensed under the MIT license.\n\n// Package main does stuff.\npackage main\n"))
+
+	rules := []TemplateRule{{TemplatePath: apache, Include: regexp.MustCompile(DefaultIncludeRegex), Replaces: []string{mit}}}
+	// No git history: values come from the old header
+	e, err := New(Options{Root: dir, Rules: rules, Git: &fakeGit{created: "2015-06-01"}})
+	if err != nil {
+		t.Fatalf("init engine: %v", err)
+	}
+	res, err := e.Process(context.Background(), []string{src}, false)
+	if err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	if res[0].Action != ActionReplace || res[0].Reason != "header matches deprecated template mit.txt" {
+		t.Fatalf("unexpected result: %+v", res[0])
+	}
+
+	if _, err := e.Process(context.Background(), []string{src}, true); err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	want := "// Copyright 2015-2019 Jane Doe <jane@doe.com>\n// Created: 2015-06-01\n// Licensed under the Apache License, Version 2.0.\n\n// Package main does stuff.\npackage main\n"
+	if got := string(mustRead(t, src)); got != want {
+		t.Fatalf("GOT:\n%s\nWANT:\n%s", got, want)
+	}
+	res, _ = e.Process(context.Background(), []string{src}, false)
+	if res[0].Action != ActionNone {
+		t.Fatalf("expected no action after migration, got: %+v", res[0])
+	}
+}
+
+func TestCarryValues(t *testing.T) {
+	data := carryValues(TemplateData{CreationDate: "2020-01-01"}, map[string]string{
+		"author":        "Jane Doe",
+		"creation_date": "2001-01-01",
+		"year_range":    "2001-2010",
+	})
+	if data.Author != "Jane Doe" || data.CreationDate != "2020-01-01" || data.CreationYear != "2020" || data.LastUpdateYear != "2010" {
+		t.Fatalf("unexpected data: %+v", data)
+	}
+	if data.YearRange != "2020" {
+		t.Fatalf("unexpected range: %q", data.YearRange)
+	}
+}
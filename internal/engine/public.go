@@ -6,12 +6,14 @@ import "bytes"
 
 // DetectHeaderBlock detects the header block in the given content.
 func DetectHeaderBlock(content []byte) (header []byte, start int, end int) {
-	return detectHeaderBlock(content)
+	return detectHeaderBlock(content, nil)
 }
 
-// HeaderSemanticallyMatches reports if the existing header semantically matches the expected header.
+// HeaderSemanticallyMatches reports if the existing header matches the expected
+// rendered header, ignoring comment markers and whitespace. Use
+// Engine.HeaderMatches to accept other values for template variables.
 func HeaderSemanticallyMatches(existing, expected []byte) bool {
-	return headerSemanticallyMatches(existing, expected)
+	return headerSemanticallyMatches(existing, expected, nil)
 }
 
 // InsertHeader inserts the given header into the given content.
@@ -31,7 +33,7 @@ func NormalizeNewlines(b []byte) []byte { return normalizeNewlines(b) }
 // RenderTemplatesFor path uses Engine's templates and Git metadata; if Engine is nil or has no templates,
 // returns nil.
 func (e *Engine) RenderTemplatesFor(path string) [][]byte {
-	trs := e.renderTemplates(path)
+	trs, _ := e.renderTemplates(path, nil)
 	out := make([][]byte, 0, len(trs))
 	for _, tr := range trs {
 		out = append(out, tr.Content)
@@ -51,14 +53,34 @@ func (e *Engine) AcceptsPath(path string) bool {
 }
 
 // RenderTemplatesForFiltered renders templates and returns only those whose
-// include/exclude patterns accept the given path.
+// include/exclude patterns accept the given path. Forbidden and
+// deprecated templates are left out.
 func (e *Engine) RenderTemplatesForFiltered(path string) [][]byte {
 	rel := e.relativePath(path)
-	trs := e.renderTemplates(path)
-	filtered := e.filterTemplatesForPath(rel, trs)
+	trs, _ := e.renderTemplates(path, nil)
+	filtered, _, _ := splitRules(e.filterTemplatesForPath(rel, trs))
 	out := make([][]byte, 0, len(filtered))
 	for _, tr := range filtered {
 		out = append(out, tr.Content)
 	}
 	return out
 }
+
+// HeaderMatches reports whether the current header of the given file content
+// matches one of the templates applicable to path, forbidden and deprecated ones aside. Unlike
+// HeaderSemanticallyMatches, Go text/template headers are matched with the
+// matcher derived from their source.
+func (e *Engine) HeaderMatches(path string, content []byte) bool {
+	content = detectTextFormat(content).normalize(content)
+	rel := e.relativePath(path)
+	trs, err := e.renderTemplates(path, content)
+	if err != nil {
+		return false
+	}
+	filtered, _, _ := splitRules(e.filterTemplatesForPath(rel, trs))
+	if len(filtered) == 0 {
+		return false
+	}
+	idx, _ := e.findMatchingTemplateIndex(rel, filtered, content, e.commentStyleForRule(filtered[0], path))
+	return idx >= 0
+}
@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"context"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestProcess_ForbiddenHeaderIsRemoved(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "old.txt")
+	mustWrite(t, old, []byte("Proprietary and confidential.\nCopyright {{ year .Now }} Evil Corp.\n"))
+	sh := filepath.Join(dir, "run.sh")
+	mustWrite(t, sh, []byte("#!/bin/sh\n\n# Proprietary and confidential.\n# Copyright 2011 Evil Corp.\n\n# Runs things.\necho run\n"))
+	goSrc := filepath.Join(dir, "main.go")
+	mustWrite(t, goSrc, []byte("//go:build linux\n\n// Proprietary and confidential.\n// Copyright 2011 Evil Corp.\n\n// Package main runs things.\npackage main\n"))
+	other := filepath.Join(dir, "other.go")
+	mustWrite(t, other, []byte("// Copyright Acme\n\npackage main\n"))
+
+	rules := []TemplateRule{{TemplatePath: old, Include: regexp.MustCompile(DefaultIncludeRegex), Forbidden: true}}
+	e, err := New(Options{Root: dir, Rules: rules, Git: &fakeGit{touched: true}})
+	if err != nil {
+		t.Fatalf("init engine: %v", err)
+	}
+	res, err := e.Process(context.Background(), []string{goSrc, sh, other}, false)
+	if err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	if res[0].Action != ActionRemove || res[0].Line != 3 || res[1].Action != ActionRemove || res[2].Action != ActionNone {
+		t.Fatalf("unexpected results: %+v", res)
+	}
+
+	if _, err := e.Process(context.Background(), []string{goSrc, sh, other}, true); err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	if got, want := string(mustRead(t, sh)), "#!/bin/sh\n\n# Runs things.\necho run\n"; got != want {
+		t.Fatalf("sh:\nGOT:\n%s\nWANT:\n%s", got, want)
+	}
+	if got, want := string(mustRead(t, goSrc)), "//go:build linux\n\n// Package main runs things.\npackage main\n"; got != want {
+		t.Fatalf("go:\nGOT:\n%s\nWANT:\n%s", got, want)
+	}
+	if got, want := string(mustRead(t, other)), "// Copyright Acme\n\npackage main\n"; got != want {
+		t.Fatalf("unrelated header must be kept, got:\n%s", got)
+	}
+}
+
+func TestProcess_ForbiddenHeaderIsReplaced(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "old.txt")
+	mustWrite(t, old, []byte("Proprietary and confidential.\n"))
+	tmpl := filepath.Join(dir, "tmpl.txt")
+	mustWrite(t, tmpl, []byte("Copyright Acme, MIT license.\n"))
+	src := filepath.Join(dir, "main.go")
+	mustWrite(t, src, []byte("// Proprietary and confidential.\n\npackage main\n"))
+
+	inc := regexp.MustCompile(DefaultIncludeRegex)
+	rules := []TemplateRule{{TemplatePath: tmpl, Include: inc}, {TemplatePath: old, Include: inc, Forbidden: true}}
+	e, _ := New(Options{Root: dir, Rules: rules, Git: &fakeGit{touched: true}})
+	res, err := e.Process(context.Background(), []string{src}, true)
+	if err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	if res[0].Action != ActionReplace {
+		t.Fatalf("expected replace, got: %+v", res[0])
+	}
+	if got, want := string(mustRead(t, src)), "// Copyright Acme, MIT license.\n\npackage main\n"; got != want {
+		t.Fatalf("GOT:\n%s\nWANT:\n%s", got, want)
+	}
+	res, _ = e.Process(context.Background(), []string{src}, false)
+	if res[0].Action != ActionNone {
+		t.Fatalf("expected no action after fix, got: %+v", res[0])
+	}
+}
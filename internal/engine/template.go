@@ -0,0 +1,210 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// TemplateData is the data model exposed to header templates written as Go
+// text/template documents (any template containing "{{").
+//
+//	{{ .Path }}            file path relative to the root, slash-separated
+//	{{ .Base }}            file base name, eg "main.go"
+//	{{ .Ext }}             file extension without the dot, eg "go"
+//	{{ .Package }}         Go package name for .go files, else the directory name
+//	{{ .Author }}          first committer of the file, empty when not available:
+//	                       {{ .Author | default "unknown" }} provides a fallback,
+//	                       which the legacy %author% variable always uses
+//	{{ .CreationDate }}    date of the first commit touching the file (YYYY-MM-DD)
+//	{{ .LastUpdateDate }}  date of the last commit touching the file (YYYY-MM-DD)
+//	{{ .CreationYear }}    year of CreationDate
+//	{{ .LastUpdateYear }}  year of LastUpdateDate
+//	{{ .YearRange }}       "2019-2025", or a single year when both are equal
+//	{{ .Vars.name }}       variables defined in configuration
+//	{{ .Now }}             current time
+type TemplateData struct {
+	Path           string
+	Base           string
+	Ext            string
+	Package        string
+	Author         string
+	CreationDate   string
+	LastUpdateDate string
+	CreationYear   string
+	LastUpdateYear string
+	YearRange      string
+	Vars           map[string]string
+	Now            time.Time
+}
+
+// templateFuncs are the helper functions available in header templates.
+var templateFuncs = template.FuncMap{
+	// year returns the year of a time or of a YYYY-MM-DD date.
+	"year": func(v interface{}) string { return formatDate("2006", v) },
+	// date formats a time or a YYYY-MM-DD date with a Go layout.
+	"date":  formatDate,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trim":  strings.TrimSpace,
+	// join concatenates a list with a separator: {{ .Vars.list | split "," | join ", " }}.
+	"join": func(sep string, elems []string) string { return strings.Join(elems, sep) },
+	// split splits a string with a separator.
+	"split": func(sep, s string) []string { return strings.Split(s, sep) },
+	// default returns value, or def when value is empty: {{ .Author | default "Acme" }}.
+	"default": func(def string, value interface{}) string {
+		if s := fmt.Sprint(value); value != nil && s != "" {
+			return s
+		}
+		return def
+	},
+}
+
+// formatDate formats a time.Time or a YYYY-MM-DD string with the given layout.
+// Unparsable or empty values yield an empty string.
+func formatDate(layout string, v interface{}) string {
+	switch t := v.(type) {
+	case time.Time:
+		return t.Format(layout)
+	case string:
+		if t == "" {
+			return ""
+		}
+		parsed, err := time.Parse("2006-01-02", t)
+		if err != nil {
+			return ""
+		}
+		return parsed.Format(layout)
+	}
+	return ""
+}
+
+// isGoTemplate reports whether a header template is a Go text/template document.
+func isGoTemplate(content []byte) bool {
+	return bytes.Contains(content, []byte("{{"))
+}
+
+// parseGoTemplate parses a header template with the helper functions.
+func parseGoTemplate(name string, content []byte) (*template.Template, error) {
+	return template.New(filepath.Base(name)).Funcs(templateFuncs).Option("missingkey=zero").Parse(string(content))
+}
+
+// templateData gathers the template data for a file. content may be nil, in
+// which case the Go package name is read from disk when needed.
+func (e *Engine) templateData(path string, content []byte) TemplateData {
+	author, _ := e.opts.Git.Author(path)
+	cr, _ := e.opts.Git.CreationDate(path)
+	lu, _ := e.opts.Git.LastUpdateDate(path)
+	base := filepath.Base(path)
+	data := TemplateData{
+		Path:           filepath.ToSlash(e.relativePath(path)),
+		Base:           base,
+		Ext:            strings.TrimPrefix(filepath.Ext(base), "."),
+		Package:        packageName(e.opts.FS, path, content),
+		Author:         author,
+		CreationDate:   cr,
+		LastUpdateDate: lu,
+		CreationYear:   formatDate("2006", cr),
+		LastUpdateYear: formatDate("2006", lu),
+		Vars:           e.opts.Variables,
+		Now:            time.Now(),
+	}
+	data.YearRange = yearRange(data.CreationYear, data.LastUpdateYear)
+	return data
+}
+
+// yearRange renders "first-last", collapsing to a single year when both are
+// equal or one of them is unknown.
+func yearRange(first, last string) string {
+	switch {
+	case first == "":
+		return last
+	case last == "" || last <= first:
+		return first
+	}
+	return first + "-" + last
+}
+
+// usesYearRange reports whether a template renders a copyright year range.
+func usesYearRange(tr TemplateRule) bool {
+	return bytes.Contains(tr.Content, []byte("%year_range%")) || bytes.Contains(tr.Content, []byte(".YearRange"))
+}
+
+// withCurrentYear re-renders a rule with the end of its year range bumped to
+// the current year, as pending changes of a touched file will be committed
+// this year. Other variables are left unchanged.
+func (e *Engine) withCurrentYear(rendered TemplateRule, path string) (TemplateRule, error) {
+	if rendered.data == nil {
+		return rendered, nil
+	}
+	data := *rendered.data
+	if now := data.Now.Format("2006"); now > data.LastUpdateYear {
+		data.LastUpdateYear = now
+	}
+	data.YearRange = yearRange(data.CreationYear, data.LastUpdateYear)
+	return e.renderRule(e.opts.Rules[rendered.idx], path, data)
+}
+
+// packageName returns the Go package name of a .go file, or the name of the
+// directory holding the file for other files.
+func packageName(fsys FS, path string, content []byte) string {
+	if strings.EqualFold(filepath.Ext(path), ".go") {
+		if content == nil {
+			content, _ = fsys.ReadFile(path)
+		}
+		// nil content would make the parser read the file from disk
+		if content != nil {
+			if f, err := parser.ParseFile(token.NewFileSet(), path, content, parser.PackageClauseOnly); err == nil {
+				return f.Name.Name
+			}
+		}
+	}
+	return filepath.Base(filepath.Dir(path))
+}
+
+// renderContent expands the variables of a template for the given data.
+func renderContent(tr TemplateRule, data TemplateData) ([]byte, error) {
+	if tr.tmpl == nil {
+		// legacy templates have no way to provide a fallback
+		author := data.Author
+		if author == "" {
+			author = "unknown"
+		}
+		s := string(tr.Content)
+		s = strings.ReplaceAll(s, "%author%", author)
+		s = strings.ReplaceAll(s, "%creation_date%", data.CreationDate)
+		s = strings.ReplaceAll(s, "%last_update_date%", data.LastUpdateDate)
+		s = strings.ReplaceAll(s, "%creation_year%", data.CreationYear)
+		s = strings.ReplaceAll(s, "%last_update_year%", data.LastUpdateYear)
+		s = strings.ReplaceAll(s, "%year_range%", data.YearRange)
+		return []byte(s), nil
+	}
+	var buf bytes.Buffer
+	if err := tr.tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("render template %s: %w", tr.TemplatePath, err)
+	}
+	// Templates often end with control actions on their own line; drop the
+	// trailing whitespace they leave so that headers stay stable.
+	return trimTrailingSpaces(buf.Bytes()), nil
+}
+
+// trimTrailingSpaces removes trailing spaces and tabs from every line.
+func trimTrailingSpaces(b []byte) []byte {
+	var out bytes.Buffer
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	sc.Buffer(make([]byte, 0, 64*1024), len(b)+1)
+	for sc.Scan() {
+		out.Write(bytes.TrimRight(sc.Bytes(), " \t"))
+		out.WriteByte('\n')
+	}
+	if !bytes.HasSuffix(b, []byte("\n")) {
+		return bytes.TrimSuffix(out.Bytes(), []byte("\n"))
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,213 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestRenderContent_GoTemplate(t *testing.T) {
+	tmpl := []byte(`Copyright {{ year .CreationDate }} {{ .Vars.company | upper }}
+File: {{ .Path }} ({{ .Ext }}, package {{ .Package }})
+Author: {{ .Author | default "nobody" }}
+Tags: {{ .Vars.tags | split "," | join ", " }}
+Built: {{ date "Jan 2006" .Now }}
+`)
+	parsed, err := parseGoTemplate("t", tmpl)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	data := TemplateData{
+		Path:         "pkg/a.go",
+		Ext:          "go",
+		Package:      "a",
+		CreationDate: "2019-05-04",
+		Vars:         map[string]string{"company": "acme", "tags": "x,y"},
+		Now:          time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+	}
+	out, err := renderContent(TemplateRule{Content: tmpl, tmpl: parsed}, data)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	want := "Copyright 2019 ACME\nFile: pkg/a.go (go, package a)\nAuthor: nobody\nTags: x, y\nBuilt: Mar 2025\n"
+	if string(out) != want {
+		t.Fatalf("got %q want %q", out, want)
+	}
+}
+
+func TestPackageName(t *testing.T) {
+	if got := packageName(OSFS{}, "/x/dir/a.go", []byte("// doc\npackage foo\n")); got != "foo" {
+		t.Fatalf("go package: %q", got)
+	}
+	fsys := FromFS(fstest.MapFS{"dir/b.go": {Data: []byte("package bar\n")}}, "/x")
+	if got := packageName(fsys, "/x/dir/b.go", nil); got != "bar" {
+		t.Fatalf("go package read from fs: %q", got)
+	}
+	if got := packageName(OSFS{}, "/x/dir/a.sh", nil); got != "dir" {
+		t.Fatalf("dir package: %q", got)
+	}
+}
+
+func TestGoTemplate_MatcherAcceptsVariableParts(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "tmpl.txt")
+	mustWrite(t, tmpl, []byte("Copyright {{ year .Now }} {{ .Vars.company }}\n\nLicensed under {{ .Vars.license }}.\n"))
+	src := filepath.Join(dir, "main.go")
+	// Written some years ago, with another license: only the year may differ
+	mustWrite(t, src, []byte("// Copyright 2001 Acme\n//\n// Licensed under MIT.\n\npackage main\n"))
+
+	rules := []TemplateRule{{TemplatePath: tmpl, Include: regexp.MustCompile(DefaultIncludeRegex)}}
+	vars := map[string]string{"company": "Acme", "license": "MIT"}
+	e, err := New(Options{Root: dir, Rules: rules, Git: &fakeGit{touched: true}, Variables: vars})
+	if err != nil {
+		t.Fatalf("init engine: %v", err)
+	}
+	res, err := e.Process(context.Background(), []string{src}, false)
+	if err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	if res[0].Action != ActionNone {
+		t.Fatalf("expected header to match, got: %+v", res[0])
+	}
+	if !e.HeaderMatches(src, mustRead(t, src)) {
+		t.Fatalf("expected HeaderMatches")
+	}
+
+	// Literal text is compared exactly
+	mustWrite(t, src, []byte("// Copyright 2001 Acme\n//\n// Distributed under MIT.\n\npackage main\n"))
+	res, err = e.Process(context.Background(), []string{src}, false)
+	if err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	if res[0].Action != ActionReplace {
+		t.Fatalf("expected replace, got: %+v", res[0])
+	}
+	if _, err = e.Process(context.Background(), []string{src}, true); err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	want := fmt.Sprintf("// Copyright %d Acme\n//\n// Licensed under MIT.\n\n", time.Now().Year())
+	if got := string(mustRead(t, src)); !strings.HasPrefix(got, want) {
+		t.Fatalf("GOT:\n%s\nWANT PREFIX:\n%s", got, want)
+	}
+}
+
+func TestGoTemplate_ParseErrorFailsNew(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "tmpl.txt")
+	mustWrite(t, tmpl, []byte("Copyright {{ .Author \n"))
+	_, err := New(Options{Root: dir, Rules: []TemplateRule{{TemplatePath: tmpl}}, Git: &fakeGit{}})
+	if err == nil {
+		t.Fatalf("expected parse error")
+	}
+}
+
+func TestYearRange(t *testing.T) {
+	cases := []struct{ first, last, want string }{
+		{"2019", "2025", "2019-2025"},
+		{"2025", "2025", "2025"},
+		{"", "2025", "2025"},
+		{"2019", "", "2019"},
+		{"", "", ""},
+	}
+	for _, c := range cases {
+		if got := yearRange(c.first, c.last); got != c.want {
+			t.Fatalf("yearRange(%q, %q) = %q, want %q", c.first, c.last, got, c.want)
+		}
+	}
+}
+
+func TestYearRange_Matching(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "tmpl.txt")
+	mustWrite(t, tmpl, []byte("Copyright %year_range% Acme\n"))
+	src := filepath.Join(dir, "main.go")
+
+	rules := []TemplateRule{{TemplatePath: tmpl, Include: regexp.MustCompile(DefaultIncludeRegex)}}
+	e, _ := New(Options{Root: dir, Rules: rules, Git: &fakeGit{created: "2019-03-01", updated: "2025-01-01"}, RespectGit: true})
+	cases := []struct {
+		header string
+		want   Action
+	}{
+		{"2019-2025", ActionNone},
+		{"2019-2023", ActionNone}, // end not bumped yet, still acceptable
+		{"2019-2030", ActionNone},
+		{"2019", ActionNone},
+		{"2018-2025", ActionReplace}, // wrong start
+		{"2019-2017", ActionReplace}, // end before creation
+		{"20192025", ActionReplace},
+	}
+	for _, c := range cases {
+		mustWrite(t, src, []byte("// Copyright "+c.header+" Acme\n\npackage main\n"))
+		res, err := e.Process(context.Background(), []string{src}, false)
+		if err != nil {
+			t.Fatalf("process error: %v", err)
+		}
+		if res[0].Action != c.want {
+			t.Fatalf("%s: expected %s, got: %+v", c.header, c.want, res[0])
+		}
+	}
+}
+
+func TestYearRange_FixBumpsEndYearOnTouchedFilesOnly(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "tmpl.txt")
+	mustWrite(t, tmpl, []byte("Copyright {{ .YearRange }} Acme\n"))
+	src := filepath.Join(dir, "main.go")
+	original := "// Copyright 2019-2021 Acme\n\npackage main\n"
+	mustWrite(t, src, []byte(original))
+
+	rules := []TemplateRule{{TemplatePath: tmpl, Include: regexp.MustCompile(DefaultIncludeRegex)}}
+	git := &fakeGit{created: "2019-03-01", updated: "2021-01-01", touched: false}
+	e, _ := New(Options{Root: dir, Rules: rules, Git: git, RespectGit: true})
+	res, err := e.Process(context.Background(), []string{src}, true)
+	if err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	if res[0].Action != ActionNone || string(mustRead(t, src)) != original {
+		t.Fatalf("untouched file must be left alone, got: %+v\n%s", res[0], mustRead(t, src))
+	}
+
+	git.touched = true
+	res, err = e.Process(context.Background(), []string{src}, true)
+	if err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	if res[0].Action != ActionReplace {
+		t.Fatalf("expected replace, got: %+v", res[0])
+	}
+	want := fmt.Sprintf("// Copyright 2019-%d Acme\n\npackage main\n", time.Now().Year())
+	if got := string(mustRead(t, src)); got != want {
+		t.Fatalf("GOT:\n%s\nWANT:\n%s", got, want)
+	}
+}
+
+func TestYearRange_InsertIntoUntrackedFileUsesCurrentYear(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "tmpl.txt")
+	mustWrite(t, tmpl, []byte("Copyright %year_range% Acme\n"))
+	src := filepath.Join(dir, "main.go")
+	mustWrite(t, src, []byte("package main\n"))
+
+	// an untracked file: touched, without history
+	rules := []TemplateRule{{TemplatePath: tmpl, Include: regexp.MustCompile(DefaultIncludeRegex)}}
+	e, _ := New(Options{Root: dir, Rules: rules, Git: &fakeGit{touched: true}, RespectGit: true})
+	want := "// Copyright " + time.Now().Format("2006") + " Acme\n"
+	res, err := e.Process(context.Background(), []string{src}, false)
+	if err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	if res[0].Action != ActionInsert || string(res[0].Expected) != want {
+		t.Fatalf("unexpected result: %+v", res[0])
+	}
+	if _, err := e.Process(context.Background(), []string{src}, true); err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	if got := string(mustRead(t, src)); got != want+"\npackage main\n" {
+		t.Fatalf("unexpected content: %q", got)
+	}
+}
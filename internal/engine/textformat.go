@@ -0,0 +1,76 @@
+package engine
+
+import "bytes"
+
+// utf8BOM is the byte order mark some editors write at the start of UTF-8 files.
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// textFormat describes the conventions of a file that headers must follow:
+// a leading BOM and the dominant line ending. The zero value is a file
+// without BOM and with LF line endings.
+type textFormat struct {
+	bom  bool
+	crlf bool
+}
+
+// detectTextFormat detects the conventions of content. CRLF line endings are
+// dominant when more lines end with CRLF than with LF alone.
+func detectTextFormat(content []byte) textFormat {
+	crlf := bytes.Count(content, []byte("\r\n"))
+	lf := bytes.Count(content, []byte("\n")) - crlf
+	return textFormat{bom: bytes.HasPrefix(content, utf8BOM), crlf: crlf > lf}
+}
+
+// normalize strips the BOM of content and turns its CRLF line endings into LF.
+func (f textFormat) normalize(content []byte) []byte {
+	if f.bom {
+		content = content[len(utf8BOM):]
+	}
+	return normalizeNewlines(content)
+}
+
+// apply turns the LF line endings of normalized text into the dominant line
+// ending of the file.
+func (f textFormat) apply(b []byte) []byte {
+	if !f.crlf || b == nil {
+		return b
+	}
+	return bytes.ReplaceAll(normalizeNewlines(b), []byte("\n"), []byte("\r\n"))
+}
+
+// originalOffset maps an offset of the normalized content to the original
+// content.
+func (f textFormat) originalOffset(raw []byte, n int) int {
+	i := 0
+	if f.bom {
+		i = len(utf8BOM)
+	}
+	for ; n > 0 && i < len(raw); n-- {
+		if raw[i] == '\r' && i+1 < len(raw) && raw[i+1] == '\n' {
+			i++
+		}
+		i++
+	}
+	return i
+}
+
+// restore maps fixed, a fix of the normalized content of raw, back to the
+// conventions of raw. Only the changed part follows the dominant line ending:
+// the rest of the file is kept byte for byte, mixed line endings included.
+func (f textFormat) restore(raw, normalized, fixed []byte) []byte {
+	prefix := 0
+	for prefix < len(normalized) && prefix < len(fixed) && normalized[prefix] == fixed[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(normalized)-prefix && suffix < len(fixed)-prefix && normalized[len(normalized)-1-suffix] == fixed[len(fixed)-1-suffix] {
+		suffix++
+	}
+	start, end := f.originalOffset(raw, prefix), f.originalOffset(raw, len(normalized)-suffix)
+	changed := f.apply(fixed[prefix : len(fixed)-suffix])
+
+	out := make([]byte, 0, start+len(changed)+len(raw)-end)
+	out = append(out, raw[:start]...)
+	out = append(out, changed...)
+	return append(out, raw[end:]...)
+}
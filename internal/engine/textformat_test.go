@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"context"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestTextFormat_Restore(t *testing.T) {
+	raw := []byte("\xef\xbb\xbfa\r\nb\nc\r\n")
+	f := detectTextFormat(raw)
+	if !f.bom || !f.crlf {
+		t.Fatalf("unexpected format: %+v", f)
+	}
+	normalized := f.normalize(raw)
+	if string(normalized) != "a\nb\nc\n" {
+		t.Fatalf("unexpected normalized content: %q", normalized)
+	}
+	if got := f.originalOffset(raw, 2); got != 6 {
+		t.Fatalf("originalOffset = %d, want 6", got)
+	}
+	// only the changed part takes the dominant line ending
+	if got, want := string(f.restore(raw, normalized, []byte("h\n\na\nb\nc\n"))), "\xef\xbb\xbfh\r\n\r\na\r\nb\nc\r\n"; got != want {
+		t.Fatalf("restore = %q, want %q", got, want)
+	}
+	if got, want := string(f.restore(raw, normalized, []byte("a\nc\n"))), "\xef\xbb\xbfa\r\nc\r\n"; got != want {
+		t.Fatalf("restore = %q, want %q", got, want)
+	}
+}
+
+func TestProcess_KeepsCRLFAndBOM(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "tmpl.txt")
+	mustWrite(t, tmpl, []byte("Copyright Acme\n"))
+	src := filepath.Join(dir, "main.go")
+	mustWrite(t, src, []byte("\xef\xbb\xbf//go:build linux\r\n\r\npackage main\r\n"))
+	matched := filepath.Join(dir, "ok.go")
+	mustWrite(t, matched, []byte("// Copyright Acme\r\n\r\npackage main\r\n"))
+
+	rules := []TemplateRule{{TemplatePath: tmpl, Include: regexp.MustCompile(DefaultIncludeRegex)}}
+	e, _ := New(Options{Root: dir, Rules: rules, Git: &fakeGit{touched: true}})
+	res, err := e.Process(context.Background(), []string{src, matched}, false)
+	if err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	if res[0].Action != ActionInsert || res[0].Offset != 3 || string(res[0].Expected) != "// Copyright Acme\r\n" {
+		t.Fatalf("unexpected result: %+v", res[0])
+	}
+	if res[1].Action != ActionNone {
+		t.Fatalf("CRLF header must match: %+v", res[1])
+	}
+
+	if _, err := e.Process(context.Background(), []string{src, matched}, true); err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	if got, want := string(mustRead(t, src)), "\xef\xbb\xbf// Copyright Acme\r\n\r\n//go:build linux\r\n\r\npackage main\r\n"; got != want {
+		t.Fatalf("GOT:\n%q\nWANT:\n%q", got, want)
+	}
+	if got, want := string(mustRead(t, matched)), "// Copyright Acme\r\n\r\npackage main\r\n"; got != want {
+		t.Fatalf("matched file must not change, got %q", got)
+	}
+}
@@ -0,0 +1,162 @@
+package engine
+
+import (
+	"bytes"
+	"strings"
+)
+
+// commentUnit is the byte range of a single comment at the top of a file:
+// either a run of consecutive line comments or one complete block comment.
+// end is always at a line boundary.
+type commentUnit struct {
+	start int
+	end   int
+	block bool
+}
+
+// defaultLineMarkers are the line comment prefixes recognized when the comment
+// style of a file is unknown.
+var defaultLineMarkers = []string{"//", "#", ";"}
+
+// defaultBlockDelims are the block comment delimiters recognized when the
+// comment style of a file is unknown. Longer openers sharing a prefix with a
+// line marker ("--[[") are listed before the others.
+var defaultBlockDelims = [][2]string{
+	{"--[[", "]]"},
+	{"/*", "*/"},
+	{"<!--", "-->"},
+	{"{-", "-}"},
+	{"(*", "*)"},
+}
+
+// lineMarkersFor returns the line comment prefixes of a style.
+func lineMarkersFor(cs *CommentStyle) []string {
+	if cs == nil {
+		return defaultLineMarkers
+	}
+	if m := strings.TrimSpace(cs.Line); m != "" {
+		return []string{m}
+	}
+	return nil
+}
+
+// blockDelimsFor returns the block comment delimiters of a style.
+func blockDelimsFor(cs *CommentStyle) [][2]string {
+	if cs == nil {
+		return defaultBlockDelims
+	}
+	open, closing := strings.TrimSpace(cs.BlockStart), strings.TrimSpace(cs.BlockEnd)
+	if open == "" || closing == "" {
+		return nil
+	}
+	return [][2]string{{open, closing}}
+}
+
+// nextLineEnd returns the offset right after the newline ending the line at pos.
+func nextLineEnd(content []byte, pos int) int {
+	if nl := bytes.IndexByte(content[pos:], '\n'); nl >= 0 {
+		return pos + nl + 1
+	}
+	return len(content)
+}
+
+// skipBlankLines advances from pos past blank lines.
+func skipBlankLines(content []byte, pos int) int {
+	for pos < len(content) {
+		end := nextLineEnd(content, pos)
+		if len(bytes.TrimSpace(content[pos:end])) != 0 {
+			break
+		}
+		pos = end
+	}
+	return pos
+}
+
+// scanBlockComment reports whether a block comment opens on the line at pos
+// and returns the offset of the end of the line where it is closed. Block
+// comments that are never closed, or that are followed by code on their last
+// line, are not considered comments.
+func scanBlockComment(content []byte, pos int, cs *CommentStyle) (int, bool) {
+	trimmed := bytes.TrimLeft(content[pos:], " \t")
+	offset := len(content) - len(trimmed)
+	for _, d := range blockDelimsFor(cs) {
+		if !bytes.HasPrefix(trimmed, []byte(d[0])) {
+			continue
+		}
+		from := offset + len(d[0])
+		idx := bytes.Index(content[from:], []byte(d[1]))
+		if idx < 0 {
+			return 0, false
+		}
+		closeEnd := from + idx + len(d[1])
+		lineEnd := nextLineEnd(content, closeEnd)
+		if len(bytes.TrimSpace(content[closeEnd:lineEnd])) != 0 {
+			return 0, false
+		}
+		return lineEnd, true
+	}
+	return 0, false
+}
+
+// hasLineMarker reports whether the trimmed line starts with a line comment marker.
+func hasLineMarker(trimmed string, cs *CommentStyle) bool {
+	for _, m := range lineMarkersFor(cs) {
+		if strings.HasPrefix(trimmed, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// scanCommentUnits tokenizes the comments found from pos on, skipping blank
+// lines between them. It stops at the first line that is neither blank nor a
+// comment, and at Go preamble directives.
+func scanCommentUnits(content []byte, pos int, cs *CommentStyle) []commentUnit {
+	var units []commentUnit
+	for pos < len(content) {
+		pos = skipBlankLines(content, pos)
+		if pos >= len(content) {
+			break
+		}
+		if end, ok := scanBlockComment(content, pos, cs); ok {
+			units = append(units, commentUnit{start: pos, end: end, block: true})
+			pos = end
+			continue
+		}
+		start := pos
+		for pos < len(content) {
+			lineEnd := nextLineEnd(content, pos)
+			line := string(content[pos:lineEnd])
+			trimmed := strings.TrimSpace(line)
+			if trimmed == "" || isGoPreambleDirective(line) || !hasLineMarker(trimmed, cs) {
+				break
+			}
+			// a block comment may follow a line comment without blank line in between
+			if _, ok := scanBlockComment(content, pos, cs); ok {
+				break
+			}
+			pos = lineEnd
+		}
+		if pos == start {
+			break
+		}
+		units = append(units, commentUnit{start: start, end: pos})
+	}
+	return units
+}
+
+// countCommentUnits returns the number of comment units of a rendered header,
+// at least 1.
+func countCommentUnits(header []byte, cs *CommentStyle) int {
+	if n := len(scanCommentUnits(header, 0, cs)); n > 0 {
+		return n
+	}
+	return 1
+}
+
+// isDocstring reports whether a header detected with the python comment style
+// is a module docstring: a string literal rather than a comment, only taken for
+// a header when it matches a template.
+func isDocstring(header []byte, cs *CommentStyle) bool {
+	return cs != nil && cs.BlockStart == `"""` && bytes.HasPrefix(bytes.TrimLeft(header, " \t\n"), []byte(`"""`))
+}
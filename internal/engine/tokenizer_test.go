@@ -0,0 +1,153 @@
+package engine
+
+import (
+	"context"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestDetectHeaderBlock_BlockComments(t *testing.T) {
+	cases := []struct {
+		name    string
+		style   string
+		content string
+		want    string
+	}{
+		{"c without stars", "slash", "/*\n  Copyright Acme\n  Licensed MIT\n*/\n\npackage x\n", "/*\n  Copyright Acme\n  Licensed MIT\n*/\n\n"},
+		{"c single line", "c-block", "/* Copyright Acme */\nbody { }\n", "/* Copyright Acme */\n"},
+		{"html", "html", "<!--\n  Copyright Acme\n-->\n<html></html>\n", "<!--\n  Copyright Acme\n-->\n"},
+		{"lua", "lua", "--[[\nCopyright Acme\n]]\nlocal x = 1\n", "--[[\nCopyright Acme\n]]\n"},
+		{"haskell", "haskell", "{-\nCopyright Acme\n-}\nmodule Main where\n", "{-\nCopyright Acme\n-}\n"},
+		{"ocaml", "ml", "(*\n Copyright Acme\n*)\nlet x = 1\n", "(*\n Copyright Acme\n*)\n"},
+		{"python docstring", "python", "\"\"\"\nCopyright Acme\n\"\"\"\nimport os\n", "\"\"\"\nCopyright Acme\n\"\"\"\n"},
+		{"line run stops at next unit", "slash", "// a\n// b\n\n// doc\npackage x\n", "// a\n// b\n\n"},
+	}
+	for _, c := range cases {
+		cs, _ := CommentStyleByName(c.style)
+		hdr, _, _ := detectHeaderBlock([]byte(c.content), &cs)
+		if string(hdr) != c.want {
+			t.Fatalf("%s: got %q want %q", c.name, hdr, c.want)
+		}
+	}
+}
+
+func TestDetectHeaderBlock_UnterminatedBlockIsNotHeader(t *testing.T) {
+	cs, _ := CommentStyleByName("slash")
+	hdr, _, _ := detectHeaderBlock([]byte("/* Copyright Acme\npackage x\n"), &cs)
+	if len(hdr) != 0 {
+		t.Fatalf("expected no header, got %q", hdr)
+	}
+	hdr, _, _ = detectHeaderBlock([]byte("/* Copyright */ package x\n"), &cs)
+	if len(hdr) != 0 {
+		t.Fatalf("expected no header when code follows the comment, got %q", hdr)
+	}
+}
+
+func TestDetectHeaderUnits_SpansSeveralComments(t *testing.T) {
+	content := []byte("// a\n\n/* b */\n\n// c\npackage x\n")
+	hdr, _, _ := detectHeaderUnits(content, nil, 2)
+	if got, want := string(hdr), "// a\n\n/* b */\n\n"; got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+	if n := countCommentUnits(content, nil); n != 3 {
+		t.Fatalf("expected 3 units, got %d", n)
+	}
+}
+
+func TestFix_ReplacesWholeBlockComment(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "tmpl.txt")
+	mustWrite(t, tmpl, []byte("Copyright Acme\n"))
+	javaSrc := filepath.Join(dir, "Main.java")
+	mustWrite(t, javaSrc, []byte("/*\n  Old license\n  with several lines\n*/\n\n// Main entry point.\nclass Main {}\n"))
+	cssSrc := filepath.Join(dir, "site.css")
+	mustWrite(t, cssSrc, []byte("/* Old\n   license */\nbody {}\n"))
+
+	rules := []TemplateRule{{TemplatePath: tmpl, Include: regexp.MustCompile(`\.(java|css)$`)}}
+	e, err := New(Options{Root: dir, Rules: rules, Git: &fakeGit{touched: true}})
+	if err != nil {
+		t.Fatalf("init engine: %v", err)
+	}
+	if _, err := e.Process(context.Background(), []string{javaSrc, cssSrc}, true); err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	want := "// Copyright Acme\n\n/*\n  Old license\n  with several lines\n*/\n\n// Main entry point.\nclass Main {}\n"
+	if got := string(mustRead(t, javaSrc)); got != want {
+		t.Fatalf("java:\nGOT:\n%s\nWANT:\n%s", got, want)
+	}
+	want = "/*\n * Copyright Acme\n */\n\n/* Old\n   license */\n\nbody {}\n"
+	if got := string(mustRead(t, cssSrc)); got != want {
+		t.Fatalf("css:\nGOT:\n%s\nWANT:\n%s", got, want)
+	}
+
+	// Both files are now stable
+	res, err := e.Process(context.Background(), []string{javaSrc, cssSrc}, false)
+	if err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	for _, r := range res {
+		if r.Action != ActionNone {
+			t.Fatalf("expected no action after fix, got: %+v", r)
+		}
+	}
+}
+
+func TestFix_RefreshesBlockHeaderInPlace(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "tmpl.txt")
+	mustWrite(t, tmpl, []byte("/*\n * Updated: %last_update_date%\n */\n"))
+	src := filepath.Join(dir, "main.go")
+	mustWrite(t, src, []byte("/*\n * Updated: 2020-01-01\n */\n\n// Package main does stuff.\npackage main\n"))
+
+	rules := []TemplateRule{{TemplatePath: tmpl, Include: regexp.MustCompile(DefaultIncludeRegex)}}
+	e, _ := New(Options{Root: dir, Rules: rules, Git: &fakeGit{updated: "2024-02-02", touched: true}, RespectGit: true})
+	res, err := e.Process(context.Background(), []string{src}, true)
+	if err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	if res[0].Action != ActionReplace {
+		t.Fatalf("expected replace, got: %+v", res[0])
+	}
+	got := string(mustRead(t, src))
+	want := "/*\n * Updated: 2024-02-02\n */\n\n// Package main does stuff.\npackage main\n"
+	if got != want {
+		t.Fatalf("GOT:\n%s\nWANT:\n%s", got, want)
+	}
+	if strings.Contains(got, "2020-01-01") {
+		t.Fatalf("old header must not be kept")
+	}
+}
+
+func TestProcess_DocstringIsNotAHeader(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "tmpl.txt")
+	mustWrite(t, tmpl, []byte("Copyright Acme\n"))
+	src := filepath.Join(dir, "mod.py")
+	mustWrite(t, src, []byte("\"\"\"Tools to frobnicate.\"\"\"\n\nimport os\n"))
+
+	rules := []TemplateRule{{TemplatePath: tmpl, Include: regexp.MustCompile(DefaultIncludeRegex)}}
+	e, err := New(Options{Root: dir, Rules: rules, Git: &fakeGit{}})
+	if err != nil {
+		t.Fatalf("init engine: %v", err)
+	}
+	res, err := e.Process(context.Background(), []string{src}, false)
+	if err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	if res[0].Action != ActionInsert || res[0].Reason != "no header found" || len(res[0].Header) != 0 {
+		t.Fatalf("expected insert, got: %+v", res[0])
+	}
+	if _, err := e.Process(context.Background(), []string{src}, true); err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	want := "# Copyright Acme\n\n\"\"\"Tools to frobnicate.\"\"\"\n\nimport os\n"
+	if got := string(mustRead(t, src)); got != want {
+		t.Fatalf("GOT:\n%q\nWANT:\n%q", got, want)
+	}
+	res, _ = e.Process(context.Background(), []string{src}, false)
+	if res[0].Action != ActionNone {
+		t.Fatalf("expected no action after fix, got: %+v", res[0])
+	}
+}
@@ -0,0 +1,155 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// ignoringGit is a fakeGit that also knows ignored paths.
+type ignoringGit struct {
+	fakeGit
+	ignored map[string]bool
+	err     error
+}
+
+func (g *ignoringGit) Ignored(_ context.Context, path string) (bool, error) {
+	return g.ignored[path], g.err
+}
+
+func TestProcess_WalkSkipsDirsAndIgnoredPaths(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "tmpl.txt")
+	mustWrite(t, tmpl, []byte("Copyright Acme\n"))
+	for _, p := range []string{"a.go", "vendor/v.go", "dist/d.go", "build/b.go", "third_party/x/t.go", "src/x/s.go", "src/gen.go"} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, p)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		mustWrite(t, filepath.Join(dir, p), []byte("package main\n"))
+	}
+	walked := func(opts Options) []string {
+		t.Helper()
+		opts.Root = dir
+		opts.Rules = []TemplateRule{{TemplatePath: tmpl, Include: regexp.MustCompile(DefaultIncludeRegex)}}
+		e, err := New(opts)
+		if err != nil {
+			t.Fatalf("init engine: %v", err)
+		}
+		res, err := e.Process(context.Background(), []string{dir}, false)
+		if err != nil {
+			t.Fatalf("process error: %v", err)
+		}
+		var out []string
+		for _, r := range res {
+			if r.Action != ActionNone {
+				rel, _ := filepath.Rel(dir, r.Path)
+				out = append(out, filepath.ToSlash(rel))
+			}
+		}
+		return out
+	}
+
+	git := &ignoringGit{fakeGit: fakeGit{touched: true}, ignored: map[string]bool{
+		filepath.Join(dir, "dist"):       true,
+		filepath.Join(dir, "src/gen.go"): true,
+	}}
+	got := strings.Join(walked(Options{Git: git}), ",")
+	if want := "a.go,build/b.go,src/x/s.go,third_party/x/t.go"; got != want {
+		t.Fatalf("default walk: got %s, want %s", got, want)
+	}
+	got = strings.Join(walked(Options{Git: git, SkipDirs: []string{"build", "third_party/*"}}), ",")
+	if want := "a.go,src/x/s.go,vendor/v.go"; got != want {
+		t.Fatalf("skip dirs walk: got %s, want %s", got, want)
+	}
+	only := []string{filepath.Join(dir, "src/x/s.go"), filepath.Join(dir, "a.go"), filepath.Join(dir, "vendor/v.go"), filepath.Join(dir, "deleted.go")}
+	got = strings.Join(walked(Options{Git: git, Only: only}), ",")
+	if want := "a.go,src/x/s.go"; got != want {
+		t.Fatalf("only walk: got %s, want %s", got, want)
+	}
+	if got := walked(Options{Git: git, Only: []string{}}); len(got) != 0 {
+		t.Fatalf("empty only walk: got %v", got)
+	}
+}
+
+func TestProcess_WalkReportsIgnoreErrors(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "tmpl.txt")
+	mustWrite(t, tmpl, []byte("Copyright Acme\n"))
+	if err := os.MkdirAll(filepath.Join(dir, "dist"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, filepath.Join(dir, "a.go"), []byte("package main\n"))
+	mustWrite(t, filepath.Join(dir, "dist/gen.go"), []byte("package main\n"))
+
+	git := &ignoringGit{fakeGit: fakeGit{touched: true}, err: errors.New("git ls-files failed")}
+	rules := []TemplateRule{{TemplatePath: tmpl, Include: regexp.MustCompile(DefaultIncludeRegex)}}
+	e, err := New(Options{Root: dir, Rules: rules, Git: git})
+	if err != nil {
+		t.Fatalf("init engine: %v", err)
+	}
+	res, err := e.Process(context.Background(), []string{dir}, false)
+	if err != nil {
+		t.Fatalf("process error: %v", err)
+	}
+	for _, r := range res {
+		if r.Err == nil {
+			t.Fatalf("%s was checked although it may be ignored", r.Path)
+		}
+		if filepath.Base(r.Path) == "gen.go" {
+			t.Fatalf("walked into %s", r.Path)
+		}
+	}
+}
+
+func TestProcess_OnlyResolvesRelativePaths(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "tmpl.txt")
+	mustWrite(t, tmpl, []byte("Copyright Acme\n"))
+	for _, p := range []string{"a.go", "b.go", "sub/c.go"} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, p)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		mustWrite(t, filepath.Join(dir, p), []byte("package main\n"))
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	rules := []TemplateRule{{TemplatePath: tmpl, Include: regexp.MustCompile(DefaultIncludeRegex)}}
+	cases := []struct {
+		only  []string
+		paths []string
+	}{
+		// absolute files, as listed by git, with relative walk paths
+		{[]string{filepath.Join(dir, "sub/c.go"), filepath.Join(dir, "a.go")}, []string{"."}},
+		{[]string{filepath.Join(dir, "sub/c.go"), filepath.Join(dir, "a.go")}, []string{"a.go", "b.go", "sub"}},
+		// relative files with an absolute walk path
+		{[]string{"sub/c.go", "./a.go"}, []string{dir}},
+	}
+	for _, c := range cases {
+		e, err := New(Options{Root: dir, Rules: rules, Git: &fakeGit{touched: true}, Only: c.only})
+		if err != nil {
+			t.Fatalf("init engine: %v", err)
+		}
+		res, err := e.Process(context.Background(), c.paths, false)
+		if err != nil {
+			t.Fatalf("process error: %v", err)
+		}
+		var got []string
+		for _, r := range res {
+			got = append(got, filepath.Base(r.Path))
+		}
+		if strings.Join(got, ",") != "a.go,c.go" {
+			t.Fatalf("only %v, paths %v: processed %v", c.only, c.paths, got)
+		}
+	}
+}
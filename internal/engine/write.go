@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// writeFile writes the fixed content of a file, unless in dry run. Symbolic
+// links reach here only with Options.FollowSymlinks.
+func (e *Engine) writeFile(path string, content []byte) error {
+	if e.opts.DryRun {
+		return nil
+	}
+	return e.opts.FS.WriteFile(path, content)
+}
+
+// writeFileAtomic replaces the content of an existing file. Content is written
+// to a temporary file of the same directory, synced, given the permissions of
+// the original file, setuid, setgid and sticky bits included, and renamed over
+// it, so that an interrupted fix never leaves a truncated file. The directory
+// is synced too, so that the rename survives a crash.
+//
+// The file is a new one: its owner and group are those of the process, and
+// hard links to the original file keep the old content.
+func writeFileAtomic(path string, content []byte) (err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".headercheck-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+	}()
+	if _, err = f.Write(content); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Chmod(info.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(f.Name(), path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir flushes the entries of a directory to disk. Directories cannot be
+// synced on Windows, where renames are durable once done.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	if err := d.Sync(); err != nil {
+		_ = d.Close()
+		return err
+	}
+	return d.Close()
+}
@@ -0,0 +1,103 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"testing"
+)
+
+func TestProcess_FixKeepsModeAndLeavesNoTempFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip on windows")
+	}
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "tmpl.txt")
+	mustWrite(t, tmpl, []byte("Copyright Acme\n"))
+	sh := filepath.Join(dir, "run.sh")
+	mustWrite(t, sh, []byte("#!/bin/sh\necho run\n"))
+	if err := os.Chmod(sh, 0o750); err != nil {
+		t.Fatal(err)
+	}
+
+	rules := []TemplateRule{{TemplatePath: tmpl, Include: regexp.MustCompile(DefaultIncludeRegex)}}
+	e, _ := New(Options{Root: dir, Rules: rules, Git: &fakeGit{touched: true}})
+	res, err := e.Process(context.Background(), []string{sh}, true)
+	if err != nil || res[0].Err != nil || res[0].Action != ActionInsert {
+		t.Fatalf("unexpected results: %+v, %v", res, err)
+	}
+	info, err := os.Stat(sh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o750 {
+		t.Fatalf("mode not kept: %v", info.Mode())
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Fatalf("temporary file left behind: %v", entries)
+	}
+}
+
+func TestProcess_SymlinksAreSkippedUnlessFollowed(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip on windows")
+	}
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "tmpl.txt")
+	mustWrite(t, tmpl, []byte("Copyright Acme\n"))
+	target := filepath.Join(dir, "target.txt")
+	mustWrite(t, target, []byte("package main\n"))
+	link := filepath.Join(dir, "link.go")
+	if err := os.Symlink("target.txt", link); err != nil {
+		t.Fatal(err)
+	}
+
+	rules := []TemplateRule{{TemplatePath: tmpl, Include: regexp.MustCompile(DefaultIncludeRegex)}}
+	e, _ := New(Options{Root: dir, Rules: rules, Git: &fakeGit{touched: true}})
+	res, err := e.Process(context.Background(), []string{link}, true)
+	if err != nil || res[0].Action != ActionNone || res[0].Skipped != "symbolic link" {
+		t.Fatalf("symlink must be skipped: %+v, %v", res, err)
+	}
+	if got := string(mustRead(t, target)); got != "package main\n" {
+		t.Fatalf("target must not change, got:\n%s", got)
+	}
+
+	e, _ = New(Options{Root: dir, Rules: rules, Git: &fakeGit{touched: true}, FollowSymlinks: true})
+	res, err = e.Process(context.Background(), []string{link}, true)
+	if err != nil || res[0].Err != nil || res[0].Action != ActionInsert {
+		t.Fatalf("unexpected results: %+v, %v", res, err)
+	}
+	if got, want := string(mustRead(t, target)), "// Copyright Acme\n\npackage main\n"; got != want {
+		t.Fatalf("target:\nGOT:\n%s\nWANT:\n%s", got, want)
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("link must stay a symbolic link: %v, %v", info, err)
+	}
+}
+
+func TestWriteFileAtomic_KeepsSetgidBit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip on windows")
+	}
+	path := filepath.Join(t.TempDir(), "tool.sh")
+	mustWrite(t, path, []byte("echo old\n"))
+	if err := os.Chmod(path, 0o755|os.ModeSetgid); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileAtomic(path, []byte("echo new\n")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&(os.ModePerm|os.ModeSetgid) != 0o755|os.ModeSetgid {
+		t.Fatalf("mode not kept: %v", info.Mode())
+	}
+	if got := string(mustRead(t, path)); got != "echo new\n" {
+		t.Fatalf("unexpected content %q", got)
+	}
+}
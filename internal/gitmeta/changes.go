@@ -0,0 +1,83 @@
+package gitmeta
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var errDisabled = errors.New("git metadata disabled")
+
+// ChangedSince lists the files under root changed since ref, such as the base
+// branch of a pull request: files committed since the merge base of ref and
+// HEAD, staged, modified or untracked. Renamed files are listed under their
+// new name, deleted and ignored files are left out. Paths are absolute and
+// sorted.
+func (g *Git) ChangedSince(ctx context.Context, ref string) ([]string, error) {
+	if g.disabled {
+		return nil, errDisabled
+	}
+	out, err := exec.CommandContext(ctx, "git", "-C", g.root, "merge-base", ref, "HEAD").Output()
+	if err != nil {
+		return nil, fmt.Errorf("no merge base of %q and HEAD, the clone may be shallow: %w", ref, err)
+	}
+	changed, err := g.listPaths(ctx, "diff", "--name-only", "-z", "--relative", "--diff-filter=d", strings.TrimSpace(string(out)))
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := g.listPaths(ctx, "ls-files", "-z", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+	return mergePaths(changed, untracked), nil
+}
+
+// Staged lists the files under root staged for the next commit, as a
+// pre-commit hook sees them. Renamed files are listed under their new name,
+// deleted files are left out. Paths are absolute and sorted.
+func (g *Git) Staged(ctx context.Context) ([]string, error) {
+	if g.disabled {
+		return nil, errDisabled
+	}
+	staged, err := g.listPaths(ctx, "diff", "--cached", "--name-only", "-z", "--relative", "--diff-filter=d")
+	if err != nil {
+		return nil, err
+	}
+	return mergePaths(staged), nil
+}
+
+// listPaths runs a git command printing NUL-separated paths relative to root
+// and returns them as absolute paths.
+func (g *Git) listPaths(ctx context.Context, args ...string) ([]string, error) {
+	out, err := exec.CommandContext(ctx, "git", append([]string{"-C", g.root}, args...)...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	var paths []string
+	for _, p := range strings.Split(string(out), "\x00") {
+		if p != "" {
+			paths = append(paths, filepath.Join(g.root, filepath.FromSlash(p)))
+		}
+	}
+	return paths, nil
+}
+
+// mergePaths returns the sorted union of lists of paths, never nil.
+func mergePaths(lists ...[]string) []string {
+	seen := map[string]bool{}
+	paths := []string{}
+	for _, list := range lists {
+		for _, p := range list {
+			if !seen[p] {
+				seen[p] = true
+				paths = append(paths, p)
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -8,12 +8,23 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
 // Git is a Git metadata provider.
 type Git struct {
 	root     string
 	disabled bool
+	// rev is the commit metadata is read at, see NewAt. Empty means the
+	// working tree.
+	rev string
+	// index is set in indexed mode, see NewIndexed.
+	index *index
+
+	// ignored is read on first use by Ignored.
+	ignoredOnce sync.Once
+	ignored     map[string]bool
+	ignoredErr  error
 }
 
 // New creates a new Git instance.
@@ -30,6 +41,58 @@ func New(ctx context.Context, root string) (*Git, error) {
 	return &Git{root: root}, nil
 }
 
+// NewAt creates a Git instance reading metadata as of the revision rev, such
+// as a tag or a commit: history after rev is ignored, and no file is touched.
+func NewAt(ctx context.Context, root, rev string) (*Git, error) {
+	g, err := New(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	// resolve rev once, so that a moving ref gives consistent results
+	out, err := exec.CommandContext(ctx, "git", "-C", root, "rev-parse", "--verify", "--quiet", rev+"^{commit}").Output()
+	if err != nil {
+		return nil, fmt.Errorf("unknown revision %q", rev)
+	}
+	g.rev = strings.TrimSpace(string(out))
+	return g, nil
+}
+
+// NewIndexed creates a Git instance that reads the whole history once, with a
+// single streamed `git log`, and the working tree status with a single
+// `git status`. Lookups are then served from memory, which is much faster than
+// New on large trees.
+func NewIndexed(ctx context.Context, root string) (*Git, error) {
+	g, err := New(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.buildIndex(ctx); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// NewIndexedAt is the indexed variant of NewAt.
+func NewIndexedAt(ctx context.Context, root, rev string) (*Git, error) {
+	g, err := NewAt(ctx, root, rev)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.buildIndex(ctx); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func (g *Git) buildIndex(ctx context.Context) error {
+	idx, err := buildIndex(ctx, g.root, g.rev)
+	if err != nil {
+		return err
+	}
+	g.index = idx
+	return nil
+}
+
 // Disabled returns a Git instance that is disabled.
 func Disabled() *Git { return &Git{disabled: true} }
 
@@ -38,9 +101,15 @@ func (g *Git) Author(path string) (string, error) {
 	if g.disabled {
 		return "", nil
 	}
-	rel, _ := filepath.Rel(g.root, path)
+	if g.index != nil {
+		return g.index.lookup(g.root, path).author, nil
+	}
+	rel, err := g.rel(path)
+	if err != nil {
+		return "", err
+	}
 	// author of first commit touching the file
-	out, err := exec.Command("git", "-C", g.root, "log", "--format=%an <%ae>", "--reverse", "--", rel).Output()
+	out, err := g.log(rel, "--format=%an <%ae>", "--reverse")
 	if err != nil {
 		return "", err
 	}
@@ -60,8 +129,14 @@ func (g *Git) CreationDate(path string) (string, error) {
 	if g.disabled {
 		return "", nil
 	}
-	rel, _ := filepath.Rel(g.root, path)
-	out, err := exec.Command("git", "-C", g.root, "log", "--format=%ad", "--date=short", "--reverse", "--", rel).Output()
+	if g.index != nil {
+		return g.index.lookup(g.root, path).created, nil
+	}
+	rel, err := g.rel(path)
+	if err != nil {
+		return "", err
+	}
+	out, err := g.log(rel, "--format=%ad", "--date=short", "--reverse")
 	if err != nil {
 		return "", err
 	}
@@ -80,20 +155,54 @@ func (g *Git) LastUpdateDate(path string) (string, error) {
 	if g.disabled {
 		return "", nil
 	}
-	rel, _ := filepath.Rel(g.root, path)
-	out, err := exec.Command("git", "-C", g.root, "log", "-1", "--format=%ad", "--date=short", "--", rel).Output()
+	if g.index != nil {
+		return g.index.lookup(g.root, path).updated, nil
+	}
+	rel, err := g.rel(path)
+	if err != nil {
+		return "", err
+	}
+	out, err := g.log(rel, "-1", "--format=%ad", "--date=short")
 	if err != nil {
 		return "", err
 	}
 	return strings.TrimSpace(string(out)), nil
 }
 
+// rel returns path relative to the repository root. Relative paths are
+// relative to the root, as in index.key.
+func (g *Git) rel(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(g.root, path)
+	}
+	return filepath.Rel(g.root, path)
+}
+
+// log runs `git log` with args on rel, from rev when set.
+func (g *Git) log(rel string, args ...string) ([]byte, error) {
+	args = append([]string{"-C", g.root, "log"}, args...)
+	if g.rev != "" {
+		args = append(args, g.rev)
+	}
+	return exec.Command("git", append(args, "--", rel)...).Output()
+}
+
 // Touched reports if file has changes compared to HEAD (or if newly added/unstaged).
+// Files are never touched at a revision.
 func (g *Git) Touched(ctx context.Context, path string) (bool, error) {
 	if g.disabled {
 		return true, nil
 	}
-	rel, _ := filepath.Rel(g.root, path)
+	if g.rev != "" {
+		return false, nil
+	}
+	if g.index != nil {
+		return g.index.touched[g.index.key(g.root, path)], nil
+	}
+	rel, err := g.rel(path)
+	if err != nil {
+		return false, err
+	}
 	// Check if file differs from HEAD (staged or unstaged) or untracked
 	// 1) git status --porcelain
 	out, err := exec.CommandContext(ctx, "git", "-C", g.root, "status", "--porcelain", "--", rel).Output()
@@ -107,3 +216,24 @@ func (g *Git) Touched(ctx context.Context, path string) (bool, error) {
 	// if untracked, status would show it, so here it's clean
 	return false, nil
 }
+
+// Ignored reports whether git ignores the file or directory, per the
+// .gitignore files, .git/info/exclude and the global excludes file. Ignored
+// paths are listed once, with a single `git ls-files`. Nothing is ignored at a
+// revision: every file of a commit is tracked.
+func (g *Git) Ignored(ctx context.Context, path string) (bool, error) {
+	if g.disabled || g.rev != "" {
+		return false, nil
+	}
+	g.ignoredOnce.Do(func() {
+		g.ignored, g.ignoredErr = readIgnored(ctx, g.root)
+	})
+	if g.ignoredErr != nil {
+		return false, g.ignoredErr
+	}
+	rel, err := g.rel(path)
+	if err != nil {
+		return false, err
+	}
+	return g.ignored[filepath.ToSlash(rel)], nil
+}
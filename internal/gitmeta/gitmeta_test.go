@@ -2,8 +2,11 @@ package gitmeta
 
 import (
 	"context"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -76,3 +79,235 @@ func TestIntegration_OnlyWhenGitAvailable(t *testing.T) {
 		t.Fatalf("expected not touched after commit")
 	}
 }
+
+func TestIndexed_MatchesPerFileQueries(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	if testing.Short() {
+		t.Skip("short mode")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("skip on windows")
+	}
+	dir := t.TempDir()
+	run := func(env []string, name string, args ...string) {
+		cmd := exec.Command(name, args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), env...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("%s %v: %v: %s", name, args, err, string(out))
+		}
+	}
+	commit := func(date, name, email, msg string) {
+		env := []string{"GIT_AUTHOR_DATE=" + date + "T12:00:00", "GIT_COMMITTER_DATE=" + date + "T12:00:00"}
+		run(env, "git", "-c", "user.name="+name, "-c", "user.email="+email, "-c", "commit.gpgsign=false", "commit", "-q", "-m", msg)
+	}
+	run(nil, "git", "init", "-q", "-b", "main")
+	run(nil, "mkdir", "sub")
+	run(nil, "bash", "-c", "echo a > sub/a.txt && echo b > b.txt")
+	run(nil, "git", "add", ".")
+	commit("2019-01-02", "Alice", "alice@example.com", "init")
+	run(nil, "bash", "-c", "echo a2 >> sub/a.txt")
+	run(nil, "git", "add", ".")
+	commit("2021-05-06", "Bob", "bob@example.com", "update")
+	run(nil, "bash", "-c", "echo dirty >> b.txt && echo new > sub/c.txt")
+
+	// Root is a subdirectory of the repository
+	root := filepath.Join(dir, "sub")
+	plain, err := New(context.Background(), root)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	indexed, err := NewIndexed(context.Background(), root)
+	if err != nil {
+		t.Fatalf("new indexed: %v", err)
+	}
+	for _, p := range []string{filepath.Join(root, "a.txt"), filepath.Join(root, "c.txt"), filepath.Join(dir, "b.txt")} {
+		for name, get := range map[string]func(*Git) (string, error){
+			"author":  func(g *Git) (string, error) { return g.Author(p) },
+			"created": func(g *Git) (string, error) { return g.CreationDate(p) },
+			"updated": func(g *Git) (string, error) { return g.LastUpdateDate(p) },
+		} {
+			want, _ := get(plain)
+			got, err := get(indexed)
+			if err != nil || got != want {
+				t.Fatalf("%s of %s: got %q (%v), want %q", name, p, got, err, want)
+			}
+		}
+		want, _ := plain.Touched(context.Background(), p)
+		got, _ := indexed.Touched(context.Background(), p)
+		if got != want {
+			t.Fatalf("touched of %s: got %v want %v", p, got, want)
+		}
+	}
+	if a, _ := indexed.Author(filepath.Join(root, "a.txt")); a != "Alice <alice@example.com>" {
+		t.Fatalf("unexpected author: %q", a)
+	}
+	if u, _ := indexed.LastUpdateDate(filepath.Join(root, "a.txt")); u != "2021-05-06" {
+		t.Fatalf("unexpected last update: %q", u)
+	}
+	if touched, _ := indexed.Touched(context.Background(), filepath.Join(root, "c.txt")); !touched {
+		t.Fatalf("untracked file should be touched")
+	}
+}
+
+func TestIgnored_ListsGitignoredPaths(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("skip on windows")
+	}
+	dir := t.TempDir()
+	run := func(name string, args ...string) {
+		cmd := exec.Command(name, args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("%s %v: %v: %s", name, args, err, string(out))
+		}
+	}
+	run("git", "init", "-q")
+	run("bash", "-c", "mkdir -p dist src && echo x > dist/app.js && echo y > src/a.go && echo z > src/a.log")
+	run("bash", "-c", "echo dist/ > .gitignore && echo '*.log' > .git/info/exclude")
+
+	g, err := New(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	for p, want := range map[string]bool{"dist": true, "src/a.log": true, "src": false, "src/a.go": false} {
+		// relative paths are relative to the root
+		for _, path := range []string{filepath.Join(dir, p), p} {
+			got, err := g.Ignored(context.Background(), path)
+			if err != nil {
+				t.Fatalf("ignored err: %v", err)
+			}
+			if got != want {
+				t.Errorf("Ignored(%s) = %v, want %v", path, got, want)
+			}
+		}
+	}
+	if ignored, _ := Disabled().Ignored(context.Background(), filepath.Join(dir, "dist")); ignored {
+		t.Fatalf("disabled git must not ignore paths")
+	}
+}
+
+func TestNewAt_ReadsHistoryUpToRevision(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("skip on windows")
+	}
+	dir := t.TempDir()
+	run := func(env []string, name string, args ...string) {
+		cmd := exec.Command(name, args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), env...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("%s %v: %v: %s", name, args, err, string(out))
+		}
+	}
+	commit := func(date, name, msg string) {
+		env := []string{"GIT_AUTHOR_DATE=" + date + "T12:00:00", "GIT_COMMITTER_DATE=" + date + "T12:00:00"}
+		run(env, "git", "-c", "user.name="+name, "-c", "user.email=dev@example.com", "-c", "commit.gpgsign=false", "commit", "-q", "-m", msg)
+	}
+	run(nil, "git", "init", "-q", "-b", "main")
+	run(nil, "bash", "-c", "echo a > a.txt")
+	run(nil, "git", "add", ".")
+	commit("2019-01-02", "Alice", "init")
+	run(nil, "git", "tag", "v1")
+	run(nil, "bash", "-c", "echo a2 >> a.txt")
+	run(nil, "git", "add", ".")
+	commit("2021-05-06", "Bob", "update")
+	run(nil, "bash", "-c", "echo dirty >> a.txt")
+
+	if _, err := NewAt(context.Background(), dir, "nope"); err == nil {
+		t.Fatalf("expected error for unknown revision")
+	}
+	p := filepath.Join(dir, "a.txt")
+	for _, open := range []func(context.Context, string, string) (*Git, error){NewAt, NewIndexedAt} {
+		g, err := open(context.Background(), dir, "v1")
+		if err != nil {
+			t.Fatalf("new at: %v", err)
+		}
+		if u, _ := g.LastUpdateDate(p); u != "2019-01-02" {
+			t.Fatalf("unexpected last update: %q", u)
+		}
+		if a, _ := g.Author(p); a != "Alice <dev@example.com>" {
+			t.Fatalf("unexpected author: %q", a)
+		}
+		if touched, _ := g.Touched(context.Background(), p); touched {
+			t.Fatalf("files are not touched at a revision")
+		}
+	}
+}
+
+func TestChangedSinceAndStaged(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("skip on windows")
+	}
+	dir := t.TempDir()
+	run := func(name string, args ...string) {
+		cmd := exec.Command(name, args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("%s %v: %v: %s", name, args, err, string(out))
+		}
+	}
+	commit := func(msg string) {
+		run("git", "-c", "user.name=Dev", "-c", "user.email=dev@example.com", "-c", "commit.gpgsign=false", "commit", "-q", "-m", msg)
+	}
+	run("git", "init", "-q", "-b", "main")
+	run("bash", "-c", "printf 'package a\\n\\nfunc A() {}\\n' > old.go && echo same > same.go && echo gone > gone.go && echo '*.log' > .gitignore")
+	run("git", "add", ".")
+	commit("init")
+	run("git", "checkout", "-q", "-b", "feature")
+	run("git", "mv", "old.go", "renamed.go")
+	run("git", "rm", "-q", "gone.go")
+	commit("rename")
+	// main moves on: its changes are not part of the branch
+	run("git", "checkout", "-q", "main")
+	run("bash", "-c", "echo main > main_only.go")
+	run("git", "add", ".")
+	commit("main")
+	run("git", "checkout", "-q", "feature")
+	run("bash", "-c", "echo staged > staged.go && echo new > untracked.go && echo x > debug.log && echo changed >> same.go")
+	run("git", "add", "staged.go")
+
+	g, err := New(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	rel := func(paths []string) string {
+		var out []string
+		for _, p := range paths {
+			r, _ := filepath.Rel(dir, p)
+			out = append(out, r)
+		}
+		return strings.Join(out, ",")
+	}
+	changed, err := g.ChangedSince(context.Background(), "main")
+	if err != nil {
+		t.Fatalf("changed since: %v", err)
+	}
+	if got, want := rel(changed), "renamed.go,same.go,staged.go,untracked.go"; got != want {
+		t.Fatalf("changed since: got %s, want %s", got, want)
+	}
+	staged, err := g.Staged(context.Background())
+	if err != nil {
+		t.Fatalf("staged: %v", err)
+	}
+	if got, want := rel(staged), "staged.go"; got != want {
+		t.Fatalf("staged: got %s, want %s", got, want)
+	}
+	if _, err := g.ChangedSince(context.Background(), "nope"); err == nil {
+		t.Fatalf("expected error for unknown ref")
+	}
+	if _, err := Disabled().Staged(context.Background()); err == nil {
+		t.Fatalf("expected error when disabled")
+	}
+}
@@ -0,0 +1,25 @@
+package gitmeta
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// readIgnored lists the paths git ignores under root, relative to root and
+// slash-separated. Ignored directories are listed once, without their content.
+func readIgnored(ctx context.Context, root string) (map[string]bool, error) {
+	out, err := exec.CommandContext(ctx, "git", "-C", root, "ls-files", "-z",
+		"--others", "--ignored", "--exclude-standard", "--directory").Output()
+	if err != nil {
+		return nil, fmt.Errorf("git ls-files: %w", err)
+	}
+	ignored := map[string]bool{}
+	for _, p := range strings.Split(string(out), "\x00") {
+		if p != "" {
+			ignored[strings.TrimSuffix(p, "/")] = true
+		}
+	}
+	return ignored, nil
+}
@@ -0,0 +1,148 @@
+package gitmeta
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// index holds the history of every file of a repository, built in one pass.
+// Keys are slash-separated paths relative to the repository top-level.
+type index struct {
+	// prefix is the path of the root relative to the repository top-level.
+	prefix  string
+	files   map[string]fileHistory
+	touched map[string]bool
+}
+
+// fileHistory is the metadata of a single file.
+type fileHistory struct {
+	author  string // author of the first commit
+	created string // date of the first commit
+	updated string // date of the last commit
+}
+
+// Separators used in the `git log` format to tell commit headers from file names.
+const (
+	recordSep = "\x1e"
+	fieldSep  = "\x1f"
+)
+
+// buildIndex reads the history up to rev, HEAD when empty. The working tree
+// status is only read without rev.
+func buildIndex(ctx context.Context, root, rev string) (*index, error) {
+	out, err := exec.CommandContext(ctx, "git", "-C", root, "rev-parse", "--show-prefix").Output()
+	if err != nil {
+		return nil, fmt.Errorf("git rev-parse: %w", err)
+	}
+	idx := &index{
+		prefix:  strings.TrimSpace(string(out)),
+		files:   map[string]fileHistory{},
+		touched: map[string]bool{},
+	}
+	if err := idx.readLog(ctx, root, rev); err != nil {
+		return nil, err
+	}
+	if rev != "" {
+		return idx, nil
+	}
+	if err := idx.readStatus(ctx, root); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// readLog streams the history from newest to oldest commit: the first commit
+// seen for a file is its last update, the last one seen is its creation.
+// Renames are reported as a deletion and an addition, like `git log -- path`.
+func (idx *index) readLog(ctx context.Context, root, rev string) error {
+	args := []string{"-C", root, "-c", "core.quotePath=false",
+		"log", "--name-only", "--no-renames", "--date=short",
+		"--format=" + recordSep + "%an <%ae>" + fieldSep + "%ad"}
+	if rev != "" {
+		args = append(args, rev)
+	}
+	cmd := exec.CommandContext(ctx, "git", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("git log: %w", err)
+	}
+	var author, date string
+	sc := bufio.NewScanner(stdout)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(line, recordSep) {
+			author, date, _ = strings.Cut(strings.TrimPrefix(line, recordSep), fieldSep)
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		h, seen := idx.files[line]
+		if !seen {
+			h.updated = date
+		}
+		h.author, h.created = author, date
+		idx.files[line] = h
+	}
+	if err := sc.Err(); err != nil {
+		_ = cmd.Wait()
+		return fmt.Errorf("git log: %w", err)
+	}
+	if err := cmd.Wait(); err != nil {
+		// a repository without commits has no history
+		if bytes.Contains(stderr.Bytes(), []byte("does not have any commits")) {
+			return nil
+		}
+		return fmt.Errorf("git log: %w", err)
+	}
+	return nil
+}
+
+// readStatus records every file with staged, unstaged or untracked changes.
+func (idx *index) readStatus(ctx context.Context, root string) error {
+	out, err := exec.CommandContext(ctx, "git", "-C", root, "status", "--porcelain", "-z", "--untracked-files=all").Output()
+	if err != nil {
+		return fmt.Errorf("git status: %w", err)
+	}
+	entries := strings.Split(string(out), "\x00")
+	for i := 0; i < len(entries); i++ {
+		e := entries[i]
+		if len(e) < 4 {
+			continue
+		}
+		idx.touched[e[3:]] = true
+		// renames and copies are followed by their original path
+		if e[0] == 'R' || e[0] == 'C' {
+			i++
+		}
+	}
+	return nil
+}
+
+// key returns the index key of a path, absolute or relative to root.
+func (idx *index) key(root, p string) string {
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(root, p)
+	}
+	rel, err := filepath.Rel(root, p)
+	if err != nil {
+		rel = p
+	}
+	return path.Clean(idx.prefix + filepath.ToSlash(rel))
+}
+
+func (idx *index) lookup(root, p string) fileHistory {
+	return idx.files[idx.key(root, p)]
+}
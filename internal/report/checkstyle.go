@@ -0,0 +1,67 @@
+package report
+
+import (
+	"encoding/xml"
+	"io"
+
+	"github.com/samber/headercheck/internal/engine"
+)
+
+type checkstyleReport struct {
+	XMLName xml.Name         `xml:"checkstyle"`
+	Version string           `xml:"version,attr"`
+	Files   []checkstyleFile `xml:"file"`
+}
+
+type checkstyleFile struct {
+	Name   string            `xml:"name,attr"`
+	Errors []checkstyleError `xml:"error"`
+}
+
+type checkstyleError struct {
+	Line     int    `xml:"line,attr"`
+	Severity string `xml:"severity,attr"`
+	Message  string `xml:"message,attr"`
+	Source   string `xml:"source,attr"`
+}
+
+// newCheckstyleReport builds the checkstyle report of results: one file entry
+// with one error per file with an issue, a warning or an error.
+func newCheckstyleReport(root string, results []engine.FileResult) checkstyleReport {
+	rep := checkstyleReport{Version: "4.3"}
+	for _, r := range results {
+		var errs []checkstyleError
+		if r.Warning != "" {
+			errs = append(errs, checkstyleError{Line: 1, Severity: "warning", Message: r.Warning, Source: "headercheck.warning"})
+		}
+		switch {
+		case r.Err != nil:
+			errs = append(errs, checkstyleError{Line: 1, Severity: "error", Message: r.Err.Error(), Source: "headercheck.error"})
+		case r.Action != engine.ActionNone && r.Action != "":
+			errs = append(errs, checkstyleError{Line: issueLine(r), Severity: "error", Message: issueMessage(root, r), Source: "headercheck." + string(r.Action)})
+		}
+		if len(errs) > 0 {
+			rep.Files = append(rep.Files, checkstyleFile{Name: relPath(root, r.Path), Errors: errs})
+		}
+	}
+	return rep
+}
+
+// WriteCheckstyle writes the checkstyle XML report of results to w.
+func WriteCheckstyle(w io.Writer, root string, results []engine.FileResult) error {
+	return writeXML(w, newCheckstyleReport(root, results))
+}
+
+// writeXML writes an indented XML document to w.
+func writeXML(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
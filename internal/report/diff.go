@@ -0,0 +1,48 @@
+package report
+
+import (
+	"fmt"
+
+	"github.com/samber/headercheck/internal/diff"
+	"github.com/samber/headercheck/internal/engine"
+)
+
+// NewDiff returns a reporter printing, for each file a fix changes, the
+// unified diff between the file in Options.FS and FileResult.NewContent. It is
+// meant for results computed with engine.Options.DryRun.
+func NewDiff(opts Options) Reporter {
+	if opts.FS == nil {
+		opts.FS = engine.OSFS{}
+	}
+	return &diffReporter{opts: opts}
+}
+
+// diffReporter prints the diff of each fixed file as it is found.
+type diffReporter struct {
+	opts Options
+}
+
+func (d *diffReporter) Report(r engine.FileResult) error {
+	if r.Warning != "" {
+		fmt.Fprintf(d.opts.Err, "warning: %s: %s\n", r.Path, r.Warning)
+	}
+	if r.Err != nil {
+		fmt.Fprintf(d.opts.Err, "error: %s: %v\n", r.Path, r.Err)
+		return nil
+	}
+	if r.NewContent == nil {
+		return nil
+	}
+	old, err := d.opts.FS.ReadFile(r.Path)
+	if err != nil {
+		fmt.Fprintf(d.opts.Err, "error: %s: %v\n", r.Path, err)
+		return nil
+	}
+	rel := relPath(d.opts.Root, r.Path)
+	_, err = d.opts.Out.Write(diff.Unified("a/"+rel, "b/"+rel, old, r.NewContent))
+	return err
+}
+
+func (d *diffReporter) Close() error {
+	return nil
+}
@@ -0,0 +1,135 @@
+// Package report renders headercheck results in machine-readable formats.
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+
+	"github.com/samber/headercheck/internal/engine"
+)
+
+// JSONReport is the document written by WriteJSON.
+type JSONReport struct {
+	// Mode is "check" or "fix".
+	Mode    string     `json:"mode"`
+	Files   []JSONFile `json:"files"`
+	Summary Summary    `json:"summary"`
+}
+
+// JSONFile is the outcome for a single file. Paths are relative to the root
+// and slash-separated.
+type JSONFile struct {
+	Path           string   `json:"path"`
+	Action         string   `json:"action"`
+	Template       string   `json:"template,omitempty"`
+	CurrentHeader  string   `json:"current_header,omitempty"`
+	ExpectedHeader string   `json:"expected_header,omitempty"`
+	Reason         string   `json:"reason,omitempty"`
+	Skipped        string   `json:"skipped,omitempty"`
+	Warnings       []string `json:"warnings,omitempty"`
+	Errors         []string `json:"errors,omitempty"`
+}
+
+// Summary counts the reported files.
+type Summary struct {
+	Files    int `json:"files"`
+	OK       int `json:"ok"`
+	Insert   int `json:"insert"`
+	Replace  int `json:"replace"`
+	Move     int `json:"move"`
+	Remove   int `json:"remove"`
+	Skipped  int `json:"skipped"`
+	Warnings int `json:"warnings"`
+	Errors   int `json:"errors"`
+}
+
+// isReported reports whether a result is part of reports: files checked
+// against a template, skipped files, and files with a warning or an error.
+// Files no template applies to are left out.
+func isReported(r engine.FileResult) bool {
+	return r.Template != "" || r.Action != engine.ActionNone || r.Skipped != "" || r.Warning != "" || r.Err != nil
+}
+
+// relPath returns p relative to root, slash-separated.
+func relPath(root, p string) string {
+	if rel, err := filepath.Rel(root, p); err == nil {
+		p = rel
+	}
+	return filepath.ToSlash(p)
+}
+
+// summarize counts the reported results.
+func summarize(results []engine.FileResult) Summary {
+	var s Summary
+	for _, r := range results {
+		if !isReported(r) {
+			continue
+		}
+		s.Files++
+		if r.Warning != "" {
+			s.Warnings++
+		}
+		switch {
+		case r.Err != nil:
+			s.Errors++
+		case r.Action == engine.ActionInsert:
+			s.Insert++
+		case r.Action == engine.ActionReplace:
+			s.Replace++
+		case r.Action == engine.ActionMove:
+			s.Move++
+		case r.Action == engine.ActionRemove:
+			s.Remove++
+		case r.Skipped != "":
+			s.Skipped++
+		default:
+			s.OK++
+		}
+	}
+	return s
+}
+
+// NewJSONReport builds the JSON report of results. root is used to make paths
+// relative.
+func NewJSONReport(root string, results []engine.FileResult, fix bool) JSONReport {
+	rep := JSONReport{Mode: "check", Files: []JSONFile{}, Summary: summarize(results)}
+	if fix {
+		rep.Mode = "fix"
+	}
+	for _, r := range results {
+		if !isReported(r) {
+			continue
+		}
+		f := JSONFile{
+			Path:           relPath(root, r.Path),
+			Action:         string(r.Action),
+			CurrentHeader:  string(r.Header),
+			ExpectedHeader: string(r.Expected),
+			Reason:         r.Reason,
+			Skipped:        r.Skipped,
+		}
+		if f.Action == "" {
+			f.Action = string(engine.ActionNone)
+		}
+		if r.Template != "" {
+			f.Template = relPath(root, r.Template)
+		}
+		if r.Warning != "" {
+			f.Warnings = []string{r.Warning}
+		}
+		if r.Err != nil {
+			f.Errors = []string{r.Err.Error()}
+		}
+		rep.Files = append(rep.Files, f)
+	}
+	return rep
+}
+
+// WriteJSON writes the JSON report of results to w.
+func WriteJSON(w io.Writer, root string, results []engine.FileResult, fix bool) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(NewJSONReport(root, results, fix))
+}
@@ -0,0 +1,42 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/samber/headercheck/internal/engine"
+)
+
+func TestWriteJSON(t *testing.T) {
+	results := []engine.FileResult{
+		{Path: "/repo/a.go", Action: engine.ActionNone, Template: "/repo/.header.txt", Header: []byte("// h\n"), Expected: []byte("// h\n")},
+		{Path: "/repo/b.go", Action: engine.ActionInsert, Template: "/repo/.header.txt", Expected: []byte("// h\n"), Reason: "no header found"},
+		{Path: "/repo/c.go", Action: engine.ActionReplace, Template: "/repo/.header.txt", Header: []byte("// old\n"), Expected: []byte("// h\n")},
+		{Path: "/repo/d.bin", Action: engine.ActionNone, Warning: "non-UTF8/binary file, forced to check"},
+		{Path: "/repo/e.go", Err: errors.New("permission denied")},
+		{Path: "/repo/logo.png", Action: engine.ActionNone},
+	}
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, "/repo", results, false); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var got JSONReport
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid json: %v\n%s", err, buf.String())
+	}
+	if got.Mode != "check" || len(got.Files) != 5 {
+		t.Fatalf("unexpected report: %+v", got)
+	}
+	if f := got.Files[2]; f.Path != "c.go" || f.Action != "replace" || f.Template != ".header.txt" || f.CurrentHeader != "// old\n" || f.ExpectedHeader != "// h\n" {
+		t.Fatalf("unexpected file: %+v", f)
+	}
+	if f := got.Files[4]; f.Action != "none" || len(f.Errors) != 1 || f.Errors[0] != "permission denied" {
+		t.Fatalf("unexpected file: %+v", f)
+	}
+	want := Summary{Files: 5, OK: 2, Insert: 1, Replace: 1, Warnings: 1, Errors: 1}
+	if got.Summary != want {
+		t.Fatalf("summary: got %+v want %+v", got.Summary, want)
+	}
+}
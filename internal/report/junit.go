@@ -0,0 +1,70 @@
+package report
+
+import (
+	"encoding/xml"
+	"io"
+
+	"github.com/samber/headercheck/internal/engine"
+)
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+	Skipped   *junitProblem `xml:"skipped,omitempty"`
+	SystemErr string        `xml:"system-err,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",cdata"`
+}
+
+// newJUnitReport builds the JUnit report of results: one test case per file
+// checked against a template, failing when the header must change. Files no
+// template applies to are left out. The report has no timing so that it is
+// deterministic.
+func newJUnitReport(root string, results []engine.FileResult) junitTestSuites {
+	suite := junitTestSuite{Name: "headercheck"}
+	for _, r := range results {
+		if !isReported(r) {
+			continue
+		}
+		tc := junitTestCase{Name: relPath(root, r.Path), ClassName: "headercheck", SystemErr: r.Warning}
+		switch {
+		case r.Err != nil:
+			tc.Error = &junitProblem{Message: r.Err.Error(), Type: "error"}
+			suite.Errors++
+		case r.Action != engine.ActionNone && r.Action != "":
+			tc.Failure = &junitProblem{Message: issueMessage(root, r), Type: string(r.Action), Text: string(r.Expected)}
+			suite.Failures++
+		case r.Skipped != "":
+			tc.Skipped = &junitProblem{Message: r.Skipped, Type: "skipped"}
+			suite.Skipped++
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	suite.Tests = len(suite.Cases)
+	return junitTestSuites{Suites: []junitTestSuite{suite}}
+}
+
+// WriteJUnit writes the JUnit XML report of results to w.
+func WriteJUnit(w io.Writer, root string, results []engine.FileResult) error {
+	return writeXML(w, newJUnitReport(root, results))
+}
@@ -0,0 +1,141 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/samber/headercheck/internal/engine"
+)
+
+// Reporter renders results. Report is called with the result of each file, in
+// order, as soon as it is decided; Close is called once all files are
+// processed. Reporters writing a single document buffer results until Close.
+type Reporter interface {
+	Report(r engine.FileResult) error
+	Close() error
+}
+
+// Options configures reporters.
+type Options struct {
+	// Root makes reported paths relative.
+	Root string
+	// Fix tells whether results come from fix mode.
+	Fix bool
+	// Verbose reports fixed, skipped and matched files in text output.
+	Verbose bool
+	// Out receives the report, Err receives the warnings and errors of the
+	// text report.
+	Out io.Writer
+	Err io.Writer
+	// FS is the file system results were computed from, diffed against by
+	// the diff reporter. Defaults to engine.OSFS.
+	FS engine.FS
+}
+
+// documentWriters write the formats made of a single document.
+var documentWriters = map[string]func(opts Options, results []engine.FileResult) error{
+	"json": func(opts Options, results []engine.FileResult) error {
+		return WriteJSON(opts.Out, opts.Root, results, opts.Fix)
+	},
+	"sarif": func(opts Options, results []engine.FileResult) error {
+		return WriteSARIF(opts.Out, opts.Root, results)
+	},
+	"checkstyle": func(opts Options, results []engine.FileResult) error {
+		return WriteCheckstyle(opts.Out, opts.Root, results)
+	},
+	"junit": func(opts Options, results []engine.FileResult) error {
+		return WriteJUnit(opts.Out, opts.Root, results)
+	},
+}
+
+// Formats returns the names of the supported formats.
+func Formats() []string {
+	formats := []string{"text"}
+	for name := range documentWriters {
+		formats = append(formats, name)
+	}
+	sort.Strings(formats[1:])
+	return formats
+}
+
+// New returns the reporter of the given format.
+func New(format string, opts Options) (Reporter, error) {
+	if format == "text" {
+		return &textReporter{opts: opts}, nil
+	}
+	write, ok := documentWriters[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q, expected one of %s", format, strings.Join(Formats(), ", "))
+	}
+	return &documentReporter{opts: opts, write: write}, nil
+}
+
+// documentReporter buffers results and writes them as a single document.
+type documentReporter struct {
+	opts    Options
+	write   func(opts Options, results []engine.FileResult) error
+	results []engine.FileResult
+}
+
+func (d *documentReporter) Report(r engine.FileResult) error {
+	d.results = append(d.results, r)
+	return nil
+}
+
+func (d *documentReporter) Close() error {
+	return d.write(d.opts, d.results)
+}
+
+// textReporter prints issues in the linter style as they are found.
+type textReporter struct {
+	opts Options
+}
+
+func (t *textReporter) Report(r engine.FileResult) error {
+	if r.Warning != "" {
+		fmt.Fprintf(t.opts.Err, "warning: %s: %s\n", r.Path, r.Warning)
+	}
+	if r.Err != nil {
+		// non-fatal per file
+		fmt.Fprintf(t.opts.Err, "error: %s: %v\n", r.Path, r.Err)
+		return nil
+	}
+	if r.Action == engine.ActionNone {
+		switch {
+		case !t.opts.Verbose:
+		case r.Skipped != "":
+			fmt.Fprintf(t.opts.Out, "skipped: %s (%s)\n", r.Path, r.Skipped)
+		case r.Template != "":
+			fmt.Fprintf(t.opts.Out, "matched: %s (%s)\n", r.Path, relPath(t.opts.Root, r.Template))
+		}
+		return nil
+	}
+	if !t.opts.Fix {
+		rel, _ := filepath.Rel(t.opts.Root, r.Path)
+		if r.Reason != "" {
+			fmt.Fprintf(t.opts.Out, "%s:%d: missing or incorrect header (%s): %s\n", rel, issueLine(r), r.Action, r.Reason)
+		} else {
+			fmt.Fprintf(t.opts.Out, "%s:%d: missing or incorrect header (%s)\n", rel, issueLine(r), r.Action)
+		}
+		return nil
+	}
+	if t.opts.Verbose {
+		fmt.Fprintf(t.opts.Out, "fixed: %s (%s)\n", r.Path, r.Action)
+	}
+	return nil
+}
+
+func (t *textReporter) Close() error {
+	return nil
+}
+
+// issueLine returns the line an issue is reported at, 1 when unknown.
+func issueLine(r engine.FileResult) int {
+	if r.Line > 0 {
+		return r.Line
+	}
+	return 1
+}
@@ -0,0 +1,83 @@
+package report
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/samber/headercheck/internal/engine"
+)
+
+func TestNew_Formats(t *testing.T) {
+	if got := strings.Join(Formats(), ","); got != "text,checkstyle,json,junit,sarif" {
+		t.Fatalf("unexpected formats: %s", got)
+	}
+	if _, err := New("yaml", Options{}); err == nil {
+		t.Fatalf("expected unknown format error")
+	}
+}
+
+func TestTextReporter(t *testing.T) {
+	var out, errOut bytes.Buffer
+	rep, err := New("text", Options{Root: "/repo", Out: &out, Err: &errOut})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	for _, r := range goldenResults {
+		if err := rep.Report(r); err != nil {
+			t.Fatalf("report: %v", err)
+		}
+	}
+	if err := rep.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	checkGolden(t, "report.txt", out.Bytes())
+	want := "warning: /repo/logo.bin: non-UTF8/binary file, forced to check\nerror: /repo/secret.go: open /repo/secret.go: permission denied\n"
+	if errOut.String() != want {
+		t.Fatalf("stderr: got %q want %q", errOut.String(), want)
+	}
+}
+
+func TestTextReporter_Verbose(t *testing.T) {
+	var out, errOut bytes.Buffer
+	rep, _ := New("text", Options{Root: "/repo", Verbose: true, Out: &out, Err: &errOut})
+	_ = rep.Report(engine.FileResult{Path: "/repo/ok.go", Action: engine.ActionNone, Template: "/repo/.header.txt"})
+	_ = rep.Report(engine.FileResult{Path: "/repo/gen.go", Action: engine.ActionNone, Skipped: "generated file"})
+	want := "matched: /repo/ok.go (.header.txt)\nskipped: /repo/gen.go (generated file)\n"
+	if out.String() != want || errOut.Len() != 0 {
+		t.Fatalf("got %q (stderr %q), want %q", out.String(), errOut.String(), want)
+	}
+}
+
+func TestDocumentReporter_WritesOnClose(t *testing.T) {
+	var out bytes.Buffer
+	rep, _ := New("junit", Options{Root: "/repo", Out: &out})
+	for _, r := range goldenResults {
+		_ = rep.Report(r)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("document written before Close")
+	}
+	if err := rep.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	checkGolden(t, "junit.xml", out.Bytes())
+}
+
+func TestDiffReporter(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(src, []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	rep := NewDiff(Options{Root: dir, Out: &out, Err: &out})
+	_ = rep.Report(engine.FileResult{Path: filepath.Join(dir, "ok.go"), Action: engine.ActionNone})
+	_ = rep.Report(engine.FileResult{Path: src, Action: engine.ActionInsert, NewContent: []byte("// Copyright Acme\n\npackage main\n")})
+	want := "--- a/main.go\n+++ b/main.go\n@@ -1 +1,3 @@\n+// Copyright Acme\n+\n package main\n"
+	if out.String() != want {
+		t.Fatalf("GOT:\n%s\nWANT:\n%s", out.String(), want)
+	}
+}
@@ -0,0 +1,236 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/samber/headercheck/internal/engine"
+)
+
+// SARIF 2.1.0 document, limited to the properties written by headercheck.
+// See https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html.
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+	// sarifSrcRoot is the base of artifact URIs, which are relative to the root.
+	sarifSrcRoot = "SRCROOT"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool        sarifTool         `json:"tool"`
+	Invocations []sarifInvocation `json:"invocations"`
+	Results     []sarifResult     `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	Name             string       `json:"name"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+	DefaultConfig    sarifConfig  `json:"defaultConfiguration"`
+}
+
+type sarifConfig struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifInvocation struct {
+	ExecutionSuccessful bool                `json:"executionSuccessful"`
+	Notifications       []sarifNotification `json:"toolExecutionNotifications,omitempty"`
+}
+
+type sarifNotification struct {
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+	Fixes     []sarifFix      `json:"fixes,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId"`
+}
+
+type sarifRegion struct {
+	StartLine  int  `json:"startLine,omitempty"`
+	EndLine    int  `json:"endLine,omitempty"`
+	ByteOffset *int `json:"byteOffset,omitempty"`
+	ByteLength *int `json:"byteLength,omitempty"`
+}
+
+type sarifFix struct {
+	Description     sarifMessage          `json:"description"`
+	ArtifactChanges []sarifArtifactChange `json:"artifactChanges"`
+}
+
+type sarifArtifactChange struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Replacements     []sarifReplacement    `json:"replacements"`
+}
+
+type sarifReplacement struct {
+	DeletedRegion   sarifRegion  `json:"deletedRegion"`
+	InsertedContent sarifMessage `json:"insertedContent"`
+}
+
+// sarifRules are the rules reported by headercheck, one per action.
+var sarifRules = []struct {
+	action engine.Action
+	rule   sarifRule
+}{
+	{engine.ActionInsert, sarifRule{ID: "headercheck/missing-header", Name: "MissingHeader", ShortDescription: sarifMessage{Text: "File has no header."}}},
+	{engine.ActionReplace, sarifRule{ID: "headercheck/wrong-header", Name: "WrongHeader", ShortDescription: sarifMessage{Text: "File header does not match the template."}}},
+	{engine.ActionMove, sarifRule{ID: "headercheck/misplaced-header", Name: "MisplacedHeader", ShortDescription: sarifMessage{Text: "File header must be placed above directives."}}},
+	{engine.ActionRemove, sarifRule{ID: "headercheck/forbidden-header", Name: "ForbiddenHeader", ShortDescription: sarifMessage{Text: "File header matches a forbidden template."}}},
+}
+
+// sarifRuleIndex returns the index of the rule reported for an action, or -1.
+func sarifRuleIndex(a engine.Action) int {
+	for i, r := range sarifRules {
+		if r.action == a {
+			return i
+		}
+	}
+	return -1
+}
+
+// newSARIFLog builds the SARIF log of results. root is used to make artifact
+// URIs relative.
+func newSARIFLog(root string, results []engine.FileResult) sarifLog {
+	driver := sarifDriver{Name: "headercheck", InformationURI: "https://github.com/samber/headercheck"}
+	for _, r := range sarifRules {
+		rule := r.rule
+		rule.DefaultConfig = sarifConfig{Level: "error"}
+		driver.Rules = append(driver.Rules, rule)
+	}
+	run := sarifRun{
+		Tool:        sarifTool{Driver: driver},
+		Invocations: []sarifInvocation{{ExecutionSuccessful: true}},
+		Results:     []sarifResult{},
+	}
+	inv := &run.Invocations[0]
+
+	for _, r := range results {
+		artifact := sarifArtifactLocation{URI: relPath(root, r.Path), URIBaseID: sarifSrcRoot}
+		if r.Warning != "" {
+			inv.Notifications = append(inv.Notifications, sarifNotification{
+				Level:     "warning",
+				Message:   sarifMessage{Text: r.Warning},
+				Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: artifact}}},
+			})
+		}
+		if r.Err != nil {
+			inv.ExecutionSuccessful = false
+			inv.Notifications = append(inv.Notifications, sarifNotification{
+				Level:     "error",
+				Message:   sarifMessage{Text: r.Err.Error()},
+				Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: artifact}}},
+			})
+			continue
+		}
+		idx := sarifRuleIndex(r.Action)
+		if idx < 0 {
+			continue
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    sarifRules[idx].rule.ID,
+			RuleIndex: idx,
+			Level:     "error",
+			Message:   sarifMessage{Text: issueMessage(root, r)},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: artifact, Region: headerRegion(r)}}},
+			Fixes:     sarifFixes(artifact, r),
+		})
+	}
+	return sarifLog{Schema: sarifSchema, Version: sarifVersion, Runs: []sarifRun{run}}
+}
+
+// issueMessage describes the issue of a file, with the action and template.
+func issueMessage(root string, r engine.FileResult) string {
+	msg := "missing or incorrect header (" + string(r.Action) + ")"
+	if r.Template != "" {
+		msg += ", template " + relPath(root, r.Template)
+	}
+	if r.Reason != "" {
+		msg += ": " + r.Reason
+	}
+	return msg
+}
+
+// headerRegion returns the lines spanned by the detected header, or the line
+// where the header is to be inserted.
+func headerRegion(r engine.FileResult) *sarifRegion {
+	if r.Line == 0 {
+		return nil
+	}
+	region := &sarifRegion{StartLine: r.Line}
+	if header := strings.TrimRight(string(r.Header), "\r\n"); header != "" {
+		region.EndLine = r.Line + strings.Count(header, "\n")
+	}
+	return region
+}
+
+// sarifFixes returns the fix of a file: the change `headercheck fix` makes,
+// so that applying it gives the same content.
+func sarifFixes(artifact sarifArtifactLocation, r engine.FileResult) []sarifFix {
+	if r.Fix == nil {
+		return nil
+	}
+	offset, length := r.Fix.Offset, r.Fix.Length
+	return []sarifFix{{
+		Description: sarifMessage{Text: string(r.Action) + " header"},
+		ArtifactChanges: []sarifArtifactChange{{
+			ArtifactLocation: artifact,
+			Replacements: []sarifReplacement{{
+				DeletedRegion:   sarifRegion{ByteOffset: &offset, ByteLength: &length},
+				InsertedContent: sarifMessage{Text: string(r.Fix.Text)},
+			}},
+		}},
+	}}
+}
+
+// WriteSARIF writes the SARIF 2.1.0 log of results to w. Files without issue
+// are left out, warnings and errors are reported as tool notifications.
+func WriteSARIF(w io.Writer, root string, results []engine.FileResult) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(newSARIFLog(root, results))
+}
@@ -0,0 +1,62 @@
+package report
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/samber/headercheck/internal/engine"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// goldenResults covers every kind of result written by reporters.
+var goldenResults = []engine.FileResult{
+	{Path: "/repo/ok.go", Action: engine.ActionNone, Template: "/repo/.header.txt", Header: []byte("// Copyright Acme\n\n"), Expected: []byte("// Copyright Acme\n"), Line: 1},
+	{Path: "/repo/cmd/main.go", Action: engine.ActionInsert, Template: "/repo/.header.txt", Expected: []byte("// Copyright Acme\n"), Reason: "no header found", Line: 1, Fix: &engine.Edit{Text: []byte("// Copyright Acme\n\n")}},
+	{Path: "/repo/run.sh", Action: engine.ActionInsert, Template: "/repo/.header.txt", Expected: []byte("# Copyright Acme\n"), Reason: "no header found", Line: 2, Offset: 20, Fix: &engine.Edit{Offset: 20, Text: []byte("# Copyright Acme\n\n")}},
+	{Path: "/repo/pkg/a.go", Action: engine.ActionReplace, Template: "/repo/.header.txt", Header: []byte("// Copyright Foo\n// All rights reserved\n\n"), Expected: []byte("// Copyright Acme\n"), Reason: `header line 1: expected "Copyright Acme", got "Copyright Foo"`, Line: 1, Fix: &engine.Edit{Offset: 13, Length: 3, Text: []byte("Acme")}},
+	{Path: "/repo/pkg/b.go", Action: engine.ActionMove, Template: "/repo/.header.txt", Header: []byte("// Copyright Acme\n\n"), Expected: []byte("// Copyright Acme\n"), Reason: "header must be placed above directives", Line: 3, Offset: 19, Fix: &engine.Edit{Length: 38, Text: []byte("// Copyright Acme\n\n//go:build go1.20\n\n")}},
+	{Path: "/repo/legacy.go", Action: engine.ActionRemove, Template: "/repo/old.txt", Header: []byte("// Proprietary\n\n"), Reason: "forbidden header found", Line: 3, Offset: 19, Fix: &engine.Edit{Offset: 19, Length: 16}},
+	{Path: "/repo/vendored.go", Action: engine.ActionNone, Skipped: "headercheck:ignore: vendored from upstream"},
+	{Path: "/repo/logo.bin", Action: engine.ActionNone, Warning: "non-UTF8/binary file, forced to check"},
+	{Path: "/repo/secret.go", Err: errors.New("open /repo/secret.go: permission denied")},
+	{Path: "/repo/README.md", Action: engine.ActionNone},
+}
+
+// checkGolden compares got with testdata/name, or updates it with -update.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("update golden: %v", err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("%s mismatch (run with -update to refresh)\nGOT:\n%s\nWANT:\n%s", name, got, want)
+	}
+}
+
+func TestWriteSARIF_Golden(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteSARIF(&buf, "/repo", goldenResults); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	checkGolden(t, "report.sarif", buf.Bytes())
+}
+
+func TestWriteSARIF_NoIssue(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteSARIF(&buf, "/repo", goldenResults[:1]); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	checkGolden(t, "empty.sarif", buf.Bytes())
+}
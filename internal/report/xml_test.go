@@ -0,0 +1,22 @@
+package report
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteCheckstyle_Golden(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCheckstyle(&buf, "/repo", goldenResults); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	checkGolden(t, "checkstyle.xml", buf.Bytes())
+}
+
+func TestWriteJUnit_Golden(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJUnit(&buf, "/repo", goldenResults); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	checkGolden(t, "junit.xml", buf.Bytes())
+}
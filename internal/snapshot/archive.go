@@ -0,0 +1,102 @@
+package snapshot
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Magic numbers of the supported archive formats.
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zipMagic  = []byte("PK\x03\x04")
+)
+
+// ReadArchive reads the tar, gzip-compressed tar or zip archive at path. The
+// format is detected from the content. When every file of the archive is
+// under a single directory, as in release tarballs, that directory is the
+// root of the returned FS. Only regular files and directories are kept.
+func ReadArchive(path string) (*FS, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f *FS
+	switch {
+	case bytes.HasPrefix(b, zipMagic):
+		f, err = readZip(b)
+	case bytes.HasPrefix(b, gzipMagic):
+		var zr *gzip.Reader
+		if zr, err = gzip.NewReader(bytes.NewReader(b)); err == nil {
+			f, err = readTar(zr)
+		}
+	default:
+		f, err = readTar(bytes.NewReader(b))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read archive %s: %w", path, err)
+	}
+	f = f.stripTopDir()
+	f.sortChildren()
+	return f, nil
+}
+
+func readTar(r io.Reader) (*FS, error) {
+	f := newFS()
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return f, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			f.addDir(hdr.Name, hdr.ModTime)
+		case tar.TypeReg:
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			f.addFile(hdr.Name, hdr.FileInfo().Mode(), hdr.ModTime, data)
+		}
+	}
+}
+
+func readZip(b []byte) (*FS, error) {
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, err
+	}
+	f := newFS()
+	for _, zf := range zr.File {
+		mode := zf.Mode()
+		switch {
+		case mode.IsDir():
+			f.addDir(zf.Name, zf.Modified)
+		case mode.IsRegular():
+			data, err := readZipFile(zf)
+			if err != nil {
+				return nil, err
+			}
+			f.addFile(zf.Name, mode, zf.Modified, data)
+		}
+	}
+	return f, nil
+}
+
+func readZipFile(zf *zip.File) ([]byte, error) {
+	rc, err := zf.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
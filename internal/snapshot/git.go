@@ -0,0 +1,185 @@
+package snapshot
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// blob is a file of a git tree.
+type blob struct {
+	name string
+	mode uint64
+	oid  string
+	size int64
+}
+
+// ReadRevision lists the files under root at the git revision rev, such as a
+// tag or a commit, from the object store: the working tree is not used. Names
+// are relative to root. Symbolic links and submodules are left out, as are the
+// files keep rejects, unless keep is nil.
+//
+// The content of files is read when they are opened, by a `git cat-file`
+// process running until ctx is done or the FS is closed.
+func ReadRevision(ctx context.Context, root, rev string, keep func(name string) bool) (*FS, error) {
+	blobs, err := listTree(ctx, root, rev)
+	if err != nil {
+		return nil, err
+	}
+	f := newFS()
+	f.blobs = &blobReader{ctx: ctx, root: root}
+	for _, b := range blobs {
+		if keep != nil && !keep(b.name) {
+			continue
+		}
+		if e := f.addFile(b.name, osMode(b.mode), time.Time{}, nil); e != nil {
+			e.oid, e.size = b.oid, b.size
+		}
+	}
+	f.sortChildren()
+	return f, nil
+}
+
+// listTree lists the regular files under root at rev.
+func listTree(ctx context.Context, root, rev string) ([]blob, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", "-C", root, "ls-tree", "-r", "-l", "-z", rev+"^{tree}")
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git ls-tree %s: %w: %s", rev, err, strings.TrimSpace(stderr.String()))
+	}
+	var blobs []blob
+	for _, line := range strings.Split(string(out), "\x00") {
+		// <mode> SP <type> SP <object> SP+ <size> TAB <file>
+		meta, name, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(meta)
+		if len(fields) != 4 || fields[1] != "blob" {
+			continue
+		}
+		mode, err := strconv.ParseUint(fields[0], 8, 32)
+		if err != nil || mode&0o170000 != 0o100000 {
+			// symbolic links
+			continue
+		}
+		size, err := strconv.ParseInt(fields[3], 10, 64)
+		if err != nil {
+			continue
+		}
+		blobs = append(blobs, blob{name: name, mode: mode, oid: fields[2], size: size})
+	}
+	return blobs, nil
+}
+
+var errClosed = errors.New("snapshot closed")
+
+// blobReader reads blobs on demand with a `git cat-file --batch` process,
+// started on the first read. Reads are serialized.
+type blobReader struct {
+	ctx  context.Context
+	root string
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	// err is set once the process cannot be used anymore.
+	err error
+}
+
+// read returns the content of the blob oid.
+func (r *blobReader) read(oid string) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cmd == nil && r.err == nil {
+		r.err = r.start()
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if _, err := fmt.Fprintln(r.stdin, oid); err != nil {
+		r.err = fmt.Errorf("git cat-file: %w", err)
+		return nil, r.err
+	}
+	data, err := readBatchObject(r.stdout)
+	if err != nil {
+		// the output may be out of sync with the requests
+		r.err = fmt.Errorf("git cat-file %s: %w", oid, err)
+		return nil, r.err
+	}
+	return data, nil
+}
+
+func (r *blobReader) start() error {
+	cmd := exec.CommandContext(r.ctx, "git", "-C", r.root, "cat-file", "--batch")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("git cat-file: %w", err)
+	}
+	r.cmd, r.stdin, r.stdout = cmd, stdin, bufio.NewReader(stdout)
+	return nil
+}
+
+// close stops the process, if started.
+func (r *blobReader) close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == errClosed {
+		return nil
+	}
+	r.err = errClosed
+	if r.cmd == nil {
+		return nil
+	}
+	_ = r.stdin.Close()
+	return r.cmd.Wait()
+}
+
+// readBatchObject reads an object from the output of `git cat-file --batch`:
+// "<oid> SP <type> SP <size> LF <content> LF".
+func readBatchObject(r *bufio.Reader) ([]byte, error) {
+	header, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(header)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("unexpected object header %q", strings.TrimSpace(header))
+	}
+	size, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("unexpected object header %q", strings.TrimSpace(header))
+	}
+	data := make([]byte, size+1)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data[:size], nil
+}
+
+// osMode converts the mode of a git blob.
+func osMode(mode uint64) fs.FileMode {
+	if mode&0o111 != 0 {
+		return 0o755
+	}
+	return 0o644
+}
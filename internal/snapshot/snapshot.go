@@ -0,0 +1,249 @@
+// Package snapshot reads trees of files into memory, from archives and git
+// revisions, and serves them as a read-only fs.FS.
+package snapshot
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FS is a read-only in-memory file system. Directories are implied by the
+// files they contain. The files of a git revision are read from the object
+// store when opened.
+type FS struct {
+	entries map[string]*entry
+	// blobs reads the files of a git revision.
+	blobs *blobReader
+}
+
+// entry is a file or a directory of an FS. It implements fs.FileInfo.
+type entry struct {
+	name     string // slash-separated path, "." for the root
+	data     []byte
+	mode     fs.FileMode
+	modTime  time.Time
+	children []string // base names, for directories
+	// oid and size identify the blob holding the content of a file of a git
+	// revision, data is nil.
+	oid  string
+	size int64
+}
+
+func newFS() *FS {
+	return &FS{entries: map[string]*entry{".": {name: ".", mode: fs.ModeDir | 0o755}}}
+}
+
+// addFile adds a regular file, along with its parent directories, and returns
+// its entry. Invalid names, such as absolute paths or paths escaping the root,
+// and names already added are ignored, the entry is nil.
+func (f *FS) addFile(name string, mode fs.FileMode, modTime time.Time, data []byte) *entry {
+	name = path.Clean(strings.TrimPrefix(name, "./"))
+	if !fs.ValidPath(name) || name == "." {
+		return nil
+	}
+	if _, exists := f.entries[name]; exists {
+		return nil
+	}
+	e := &entry{name: name, data: data, mode: mode.Perm(), modTime: modTime}
+	f.entries[name] = e
+	f.link(name, modTime)
+	return e
+}
+
+// addDir adds a directory, along with its parent directories.
+func (f *FS) addDir(name string, modTime time.Time) {
+	name = path.Clean(strings.TrimPrefix(name, "./"))
+	if !fs.ValidPath(name) || name == "." {
+		return
+	}
+	if _, exists := f.entries[name]; exists {
+		return
+	}
+	f.entries[name] = &entry{name: name, mode: fs.ModeDir | 0o755, modTime: modTime}
+	f.link(name, modTime)
+}
+
+// link records name in its parent directory, creating it when missing.
+func (f *FS) link(name string, modTime time.Time) {
+	dir := path.Dir(name)
+	f.addDir(dir, modTime)
+	parent := f.entries[dir]
+	if parent == nil || !parent.IsDir() {
+		return
+	}
+	parent.children = append(parent.children, path.Base(name))
+}
+
+// stripTopDir removes the directory holding every file of the archive, such as
+// the project-1.2.3/ directory of release tarballs.
+func (f *FS) stripTopDir() *FS {
+	root := f.entries["."]
+	if len(root.children) != 1 {
+		return f
+	}
+	top := root.children[0]
+	if !f.entries[top].IsDir() {
+		return f
+	}
+	stripped := newFS()
+	for name, e := range f.entries {
+		rel := strings.TrimPrefix(name, top+"/")
+		if rel == name {
+			continue
+		}
+		if e.IsDir() {
+			stripped.addDir(rel, e.modTime)
+		} else {
+			stripped.addFile(rel, e.mode, e.modTime, e.data)
+		}
+	}
+	return stripped
+}
+
+// sortChildren sorts the entries of directories by name, once the FS is built.
+func (f *FS) sortChildren() {
+	for _, e := range f.entries {
+		sort.Strings(e.children)
+	}
+}
+
+func (f *FS) lookup(op, name string) (*entry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	e, ok := f.entries[name]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return e, nil
+}
+
+// Close stops reading the files of a git revision. Files cannot be read
+// anymore.
+func (f *FS) Close() error {
+	if f.blobs == nil {
+		return nil
+	}
+	return f.blobs.close()
+}
+
+// content returns the content of a file, shared with the entry unless read
+// from git.
+func (f *FS) content(e *entry) ([]byte, error) {
+	if e.oid == "" {
+		return e.data, nil
+	}
+	data, err := f.blobs.read(e.oid)
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: e.name, Err: err}
+	}
+	return data, nil
+}
+
+// Open implements fs.FS.
+func (f *FS) Open(name string) (fs.File, error) {
+	e, err := f.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := f.content(e)
+	if err != nil {
+		return nil, err
+	}
+	return &file{fsys: f, entry: e, r: bytes.NewReader(data)}, nil
+}
+
+// Stat implements fs.StatFS.
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	return f.lookup("stat", name)
+}
+
+// ReadFile implements fs.ReadFileFS. The returned slice is a copy.
+func (f *FS) ReadFile(name string) ([]byte, error) {
+	e, err := f.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if e.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+	if e.oid != "" {
+		return f.content(e)
+	}
+	return append([]byte(nil), e.data...), nil
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	e, err := f.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !e.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	return f.dirEntries(e), nil
+}
+
+func (f *FS) dirEntries(dir *entry) []fs.DirEntry {
+	entries := make([]fs.DirEntry, 0, len(dir.children))
+	for _, child := range dir.children {
+		entries = append(entries, fs.FileInfoToDirEntry(f.entries[path.Join(dir.name, child)]))
+	}
+	return entries
+}
+
+func (e *entry) Name() string { return path.Base(e.name) }
+func (e *entry) Size() int64 {
+	if e.oid != "" {
+		return e.size
+	}
+	return int64(len(e.data))
+}
+
+func (e *entry) Mode() fs.FileMode  { return e.mode }
+func (e *entry) ModTime() time.Time { return e.modTime }
+func (e *entry) IsDir() bool        { return e.mode.IsDir() }
+func (e *entry) Sys() interface{}   { return nil }
+
+// file is an open entry of an FS.
+type file struct {
+	fsys  *FS
+	entry *entry
+	r     *bytes.Reader
+	// dirPos is the number of directory entries already read.
+	dirPos int
+}
+
+func (f *file) Stat() (fs.FileInfo, error) { return f.entry, nil }
+func (f *file) Close() error               { return nil }
+
+func (f *file) Read(b []byte) (int, error) {
+	if f.entry.IsDir() {
+		return 0, &fs.PathError{Op: "read", Path: f.entry.name, Err: fs.ErrInvalid}
+	}
+	return f.r.Read(b)
+}
+
+// ReadDir implements fs.ReadDirFile.
+func (f *file) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !f.entry.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: f.entry.name, Err: fs.ErrInvalid}
+	}
+	entries := f.fsys.dirEntries(f.entry)[f.dirPos:]
+	if n > 0 {
+		if len(entries) == 0 {
+			return nil, io.EOF
+		}
+		if len(entries) > n {
+			entries = entries[:n]
+		}
+	}
+	f.dirPos += len(entries)
+	return entries, nil
+}
@@ -0,0 +1,170 @@
+package snapshot
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+var archiveFiles = map[string]string{
+	"project-1.2.3/main.go":     "package main\n",
+	"project-1.2.3/lib/util.go": "package lib\n",
+}
+
+func writeTar(t *testing.T, path string, compress bool) {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	// git archive starts with a pax global header, which is not a file
+	if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeXGlobalHeader, Name: "pax_global_header", PAXRecords: map[string]string{"comment": "abc"}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"project-1.2.3/lib/util.go", "project-1.2.3/main.go"} {
+		content := archiveFiles[name]
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0o644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeSymlink, Name: "project-1.2.3/link.go", Linkname: "main.go"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	if compress {
+		var zbuf bytes.Buffer
+		zw := gzip.NewWriter(&zbuf)
+		_, _ = zw.Write(b)
+		_ = zw.Close()
+		b = zbuf.Bytes()
+	}
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadArchive_TarAndZip(t *testing.T) {
+	dir := t.TempDir()
+	writeTar(t, filepath.Join(dir, "release.tar"), false)
+	writeTar(t, filepath.Join(dir, "release.tar.gz"), true)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range archiveFiles {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write([]byte(content))
+	}
+	_ = zw.Close()
+	if err := os.WriteFile(filepath.Join(dir, "release.zip"), buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"release.tar", "release.tar.gz", "release.zip"} {
+		f, err := ReadArchive(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		// the top directory is stripped, the symbolic link is left out
+		if err := fstest.TestFS(f, "main.go", "lib/util.go"); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if _, err := fs.Stat(f, "link.go"); err == nil {
+			t.Fatalf("%s: symbolic links must be left out", name)
+		}
+		b, err := fs.ReadFile(f, "lib/util.go")
+		if err != nil || string(b) != "package lib\n" {
+			t.Fatalf("%s: unexpected content %q (%v)", name, b, err)
+		}
+	}
+}
+
+func TestReadArchive_KeepsRootWithSeveralEntries(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range []string{"a/x.go", "b.go"} {
+		w, _ := zw.Create(name)
+		_, _ = w.Write([]byte("package x\n"))
+	}
+	_ = zw.Close()
+	path := filepath.Join(t.TempDir(), "src.zip")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := ReadArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(f, "a/x.go", "b.go"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadRevision_ReadsObjectStore(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("skip on windows")
+	}
+	dir := t.TempDir()
+	run := func(name string, args ...string) {
+		cmd := exec.Command(name, args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("%s %v: %v: %s", name, args, err, string(out))
+		}
+	}
+	run("git", "init", "-q", "-b", "main")
+	run("bash", "-c", "mkdir -p sub/pkg && echo v1 > sub/pkg/a.go && echo notes > sub/pkg/notes.txt && echo top > top.go && ln -s pkg/a.go sub/link.go")
+	run("git", "add", ".")
+	run("git", "-c", "user.name=Dev", "-c", "user.email=dev@example.com", "-c", "commit.gpgsign=false", "commit", "-q", "-m", "init")
+	run("git", "tag", "v1")
+	run("bash", "-c", "echo v2 > sub/pkg/a.go && echo new > sub/b.go")
+
+	// Root is a subdirectory of the repository
+	keep := func(name string) bool { return strings.HasSuffix(name, ".go") }
+	f, err := ReadRevision(context.Background(), filepath.Join(dir, "sub"), "v1", keep)
+	if err != nil {
+		t.Fatalf("read revision: %v", err)
+	}
+	if err := fstest.TestFS(f, "pkg/a.go"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"b.go", "link.go", "top.go", "pkg/notes.txt"} {
+		if _, err := fs.Stat(f, name); err == nil {
+			t.Fatalf("%s must not be read", name)
+		}
+	}
+	if b, _ := fs.ReadFile(f, "pkg/a.go"); string(b) != "v1\n" {
+		t.Fatalf("unexpected content %q", b)
+	}
+	if info, _ := fs.Stat(f, "pkg/a.go"); info.Size() != 3 {
+		t.Fatalf("unexpected size %d", info.Size())
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if _, err := fs.ReadFile(f, "pkg/a.go"); err == nil {
+		t.Fatalf("files must not be read once closed")
+	}
+	if _, err := ReadRevision(context.Background(), dir, "nope", nil); err == nil {
+		t.Fatalf("expected error for unknown revision")
+	}
+}
@@ -2,14 +2,11 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"os"
 	"path/filepath"
-	"regexp"
 
-	"github.com/samber/headercheck/internal/engine"
-	"github.com/samber/headercheck/internal/gitmeta"
+	"github.com/samber/headercheck"
 	"golang.org/x/tools/go/analysis"
 )
 
@@ -27,10 +24,11 @@ func New(conf any) ([]*analysis.Analyzer, error) { //nolint: revive
 	cfg := parseConfig(conf)
 	root := resolveRoot()
 	normalizeTemplatePathsAndDefaults(root, &cfg)
-	gm := gitOrDisabled(root)
-	rules := compileRules(cfg)
-	a := buildAnalyzer(root, gm, rules)
-	return []*analysis.Analyzer{a}, nil
+	c, err := headercheck.New(headercheck.Options{Root: root, Config: compileConfig(cfg), Git: gitOrDisabled(root)})
+	if err != nil {
+		return nil, err
+	}
+	return []*analysis.Analyzer{buildAnalyzer(c)}, nil
 }
 
 func parseConfig(conf any) pluginConfig {
@@ -91,89 +89,85 @@ func normalizeTemplatePathsAndDefaults(root string, cfg *pluginConfig) {
 			cfg.Templates[i].Path = filepath.Join(root, t.Path)
 		}
 		if cfg.Templates[i].Include == "" {
-			cfg.Templates[i].Include = engine.DefaultIncludeRegex
+			cfg.Templates[i].Include = headercheck.DefaultIncludeRegex
 		}
 	}
 }
 
-func gitOrDisabled(root string) *gitmeta.Git {
-	gm, err := gitmeta.New(context.Background(), root)
+func gitOrDisabled(root string) headercheck.GitMetadata {
+	gm, err := headercheck.OpenGit(context.Background(), root, true)
 	if err != nil {
-		return gitmeta.Disabled()
+		return nil
 	}
 	return gm
 }
 
-func compileRules(cfg pluginConfig) []engine.TemplateRule {
-	var rules []engine.TemplateRule
+func compileConfig(cfg pluginConfig) headercheck.Config {
+	var out headercheck.Config
 	for _, t := range cfg.Templates {
-		incRx := regexp.MustCompile(t.Include)
-		var excRx *regexp.Regexp
-		if t.Exclude != "" {
-			excRx = regexp.MustCompile(t.Exclude)
-		}
-		rules = append(rules, engine.TemplateRule{TemplatePath: t.Path, Include: incRx, Exclude: excRx})
+		out.Templates = append(out.Templates, headercheck.TemplateDef{Path: t.Path, Include: t.Include, Exclude: t.Exclude})
 	}
-	return rules
+	return out
 }
 
-func buildAnalyzer(root string, gm *gitmeta.Git, rules []engine.TemplateRule) *analysis.Analyzer {
+func buildAnalyzer(c *headercheck.Checker) *analysis.Analyzer {
 	return &analysis.Analyzer{
 		Name: "headercheck",
 		Doc:  "checks presence of file headers in Go files",
 		Run: func(pass *analysis.Pass) (interface{}, error) {
 			for _, f := range pass.Files {
-				filePath := pass.Fset.File(f.Pos()).Name()
-				content, err := os.ReadFile(filePath)
+				tf := pass.Fset.File(f.Pos())
+				content, err := readFile(pass, tf.Name())
 				if err != nil {
 					continue
 				}
-				en, _ := engine.New(engine.Options{Root: root, Rules: rules, Git: gm})
-				if en == nil {
-					continue
-				}
-				if !en.AcceptsPath(filePath) {
-					// no template applies to this file
+				r := c.CheckContent(context.Background(), tf.Name(), content)
+				if r.Err != nil || r.Action == headercheck.ActionNone || r.Offset > tf.Size() {
+					// no template applies, the file is skipped or its header is fine
 					continue
 				}
-				rendered := en.RenderTemplatesForFiltered(filePath)
-				if len(rendered) == 0 {
-					continue
+				message, fixMessage := diagnosticMessages(r)
+				d := analysis.Diagnostic{Pos: tf.Pos(r.Offset), Message: message}
+				// r.Fix is the range of the file FixContent rewrites
+				if r.Fix != nil && r.Fix.Offset+r.Fix.Length <= tf.Size() {
+					d.SuggestedFixes = []analysis.SuggestedFix{{
+						Message: fixMessage,
+						TextEdits: []analysis.TextEdit{{
+							Pos:     tf.Pos(r.Fix.Offset),
+							End:     tf.Pos(r.Fix.Offset + r.Fix.Length),
+							NewText: r.Fix.Text,
+						}},
+					}}
 				}
-				cur, hs, he := engine.DetectHeaderBlock(content)
-				_ = hs
-				_ = he
-				matchedIdx := -1
-				for i, tmpl := range rendered {
-					if engine.HeaderSemanticallyMatches(cur, tmpl) {
-						matchedIdx = i
-						break
-					}
-				}
-				if matchedIdx >= 0 {
-					continue
-				}
-				insertPos := f.Package
-				newHeader := []byte{}
-				if len(rendered) > 0 {
-					newHeader = rendered[0]
-				}
-				edits := []analysis.TextEdit{{
-					Pos:     insertPos,
-					End:     insertPos,
-					NewText: append(append([]byte{}, newHeader...), '\n'),
-				}}
-				pass.Report(analysis.Diagnostic{
-					Pos:     insertPos,
-					Message: "missing or incorrect file header",
-					SuggestedFixes: []analysis.SuggestedFix{{
-						Message:   "Add header",
-						TextEdits: edits,
-					}},
-				})
-				_ = bytes.MinRead
+				pass.Report(d)
 			}
 			return nil, nil
 		},
 	}
 }
+
+// diagnosticMessages returns the message of the diagnostic reported for a
+// result and the message of its suggested fix.
+func diagnosticMessages(r headercheck.Result) (message, fixMessage string) {
+	switch r.Action {
+	case headercheck.ActionInsert:
+		message, fixMessage = "missing file header", "Add header"
+	case headercheck.ActionMove:
+		message, fixMessage = "file header must be placed above directives", "Move header"
+	case headercheck.ActionRemove:
+		message, fixMessage = "forbidden file header", "Remove header"
+	default:
+		message, fixMessage = "incorrect file header", "Replace header"
+		if r.Reason != "" {
+			message += ": " + r.Reason
+		}
+	}
+	return message, fixMessage
+}
+
+func readFile(pass *analysis.Pass, filename string) ([]byte, error) {
+	if pass.ReadFile != nil {
+		return pass.ReadFile(filename)
+	}
+	return os.ReadFile(filename)
+}